@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// aggregateCollector is one of the endpoints served together on /metrics.
+type aggregateCollector struct {
+	name    string
+	collect func(ctx context.Context, grpcConn *grpc.ClientConn) *prometheus.Registry
+}
+
+// getAggregateCollectors returns the collectors served on /metrics. The validator and wallet
+// ones are only included if there are default addresses to query, the rest of the endpoints
+// are too heavy to be queried on every scrape and should have their own jobs.
+func getAggregateCollectors() []aggregateCollector {
+	collectors := []aggregateCollector{
+		{name: "general", collect: getGeneralRegistry},
+		{name: "params", collect: getParamsRegistry},
+	}
+
+	if addresses := getDefaultValidatorAddresses(); len(addresses) > 0 {
+		collectors = append(collectors, aggregateCollector{
+			name: "validator",
+			collect: func(ctx context.Context, grpcConn *grpc.ClientConn) *prometheus.Registry {
+				return getValidatorRegistry(ctx, grpcConn, addresses)
+			},
+		})
+	}
+
+	if addresses := getDefaultWalletAddresses(); len(addresses) > 0 {
+		collectors = append(collectors, aggregateCollector{
+			name: "wallet",
+			collect: func(ctx context.Context, grpcConn *grpc.ClientConn) *prometheus.Registry {
+				return getWalletRegistry(ctx, grpcConn, addresses, "")
+			},
+		})
+	}
+
+	return collectors
+}
+
+// AggregateHandler serves the metrics of the general, params, and the default validator and wallet
+// endpoints at once on /metrics, so the exporter can be scraped by a single Prometheus job.
+// The collectors are run concurrently, each into its own registry, and the ones that didn't finish
+// in time are skipped, so a single slow collector doesn't fail the whole scrape.
+func AggregateHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := zerolog.Ctx(r.Context())
+
+	collectors := getAggregateCollectors()
+	registries := make([]*prometheus.Registry, len(collectors))
+
+	var wg sync.WaitGroup
+
+	for index, collector := range collectors {
+		wg.Add(1)
+		go func(index int, collector aggregateCollector) {
+			defer wg.Done()
+
+			registry := collector.collect(r.Context(), grpcConn)
+			if err := r.Context().Err(); err != nil {
+				sublogger.Warn().
+					Str("collector", collector.name).
+					Err(err).
+					Msg("Collector didn't finish in time, skipping it")
+				return
+			}
+
+			registries[index] = registry
+		}(index, collector)
+	}
+
+	wg.Wait()
+
+	var finished []*prometheus.Registry
+	for _, registry := range registries {
+		if registry != nil {
+			finished = append(finished, registry)
+		}
+	}
+
+	if len(finished) == 0 && handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(finished...), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics").
+		Int("collectors", len(finished)).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
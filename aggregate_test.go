@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGetGathererKeepsExemplars(t *testing.T) {
+	defer func(prefix string) { MetricsPrefix = prefix }(MetricsPrefix)
+	MetricsPrefix = "custom"
+
+	generalRegistry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cosmos_latest_block_height_exemplar_total",
+		Help: "Latest block height",
+	})
+	counter.(prometheus.ExemplarAdder).AddWithExemplar(100, prometheus.Labels{"block_hash": "ABCDEF"})
+	generalRegistry.MustRegister(counter)
+
+	paramsRegistry := prometheus.NewRegistry()
+	paramsRegistry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cosmos_params_max_validators",
+		Help: "Active set length",
+	}))
+
+	families, err := getGatherer(generalRegistry, paramsRegistry).Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, family := range families {
+		found[family.GetName()] = true
+
+		if family.GetName() != "custom_latest_block_height_exemplar_total" {
+			continue
+		}
+
+		exemplar := family.GetMetric()[0].GetCounter().GetExemplar()
+		if exemplar == nil {
+			t.Fatalf("exemplar is dropped")
+		}
+
+		if exemplar.GetLabel()[0].GetValue() != "ABCDEF" {
+			t.Errorf("got exemplar %v, want block_hash ABCDEF", exemplar.GetLabel())
+		}
+	}
+
+	for _, name := range []string{"custom_latest_block_height_exemplar_total", "custom_params_max_validators"} {
+		if !found[name] {
+			t.Errorf("%s is not gathered", name)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/simapp"
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"google.golang.org/grpc"
+)
+
+// Listing all the accounts is only possible since cosmos-sdk v0.43, while we're built
+// against an older one, so the messages are declared here.
+const authAccountsMethod = "/cosmos.auth.v1beta1.Query/Accounts"
+
+// concentrationMaxAccounts is the hard limit of accounts to go through, as each of them
+// needs a separate balance query. On chains with more accounts the metric is not reported.
+const concentrationMaxAccounts = 10000
+
+type authAccountsRequest struct {
+	Pagination *querytypes.PageRequest `protobuf:"bytes,1,opt,name=pagination,proto3"`
+}
+
+func (m *authAccountsRequest) Reset()         { *m = authAccountsRequest{} }
+func (m *authAccountsRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*authAccountsRequest) ProtoMessage()    {}
+
+type authAccountsResponse struct {
+	Accounts   []*codectypes.Any        `protobuf:"bytes,1,rep,name=accounts,proto3"`
+	Pagination *querytypes.PageResponse `protobuf:"bytes,2,opt,name=pagination,proto3"`
+}
+
+func (m *authAccountsResponse) Reset()         { *m = authAccountsResponse{} }
+func (m *authAccountsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*authAccountsResponse) ProtoMessage()    {}
+
+// getAccountAddresses pages through all the accounts, skipping the module ones,
+// as the pools they hold are not owned by anyone.
+func getAccountAddresses(ctx context.Context, grpcConn *grpc.ClientConn) ([]string, error) {
+	encCfg := simapp.MakeTestEncodingConfig()
+	interfaceRegistry := encCfg.InterfaceRegistry
+
+	var addresses []string
+	var nextKey []byte
+
+	for {
+		response := &authAccountsResponse{}
+		if err := grpcConn.Invoke(ctx, authAccountsMethod, &authAccountsRequest{
+			Pagination: &querytypes.PageRequest{
+				Key:   nextKey,
+				Limit: Limit,
+			},
+		}, response); err != nil {
+			return nil, err
+		}
+
+		for _, accountAny := range response.Accounts {
+			var account authtypes.AccountI
+			if err := interfaceRegistry.UnpackAny(accountAny, &account); err != nil {
+				return nil, err
+			}
+
+			if _, ok := account.(authtypes.ModuleAccountI); ok {
+				continue
+			}
+
+			addresses = append(addresses, account.GetAddress().String())
+			if len(addresses) > concentrationMaxAccounts {
+				return nil, fmt.Errorf("chain has more than %d accounts", concentrationMaxAccounts)
+			}
+		}
+
+		if response.Pagination == nil || len(response.Pagination.NextKey) == 0 {
+			return addresses, nil
+		}
+
+		nextKey = response.Pagination.NextKey
+	}
+}
+
+// getTopNSupplyShare returns the share of the staking denom supply held by the --concentration-top-n
+// accounts with the largest balances. Only the liquid balances are counted, delegated tokens are not.
+func getTopNSupplyShare(ctx context.Context, grpcConn *grpc.ClientConn) (float64, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	paramsResponse, err := stakingClient.Params(ctx, &stakingtypes.QueryParamsRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	bondDenom := paramsResponse.Params.BondDenom
+
+	bankClient := banktypes.NewQueryClient(grpcConn)
+	supplyResponse, err := bankClient.SupplyOf(ctx, &banktypes.QuerySupplyOfRequest{Denom: bondDenom})
+	if err != nil {
+		return 0, err
+	}
+
+	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+	supply, err := strconv.ParseFloat(supplyResponse.Amount.Amount.String(), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if supply == 0 {
+		return 0, nil
+	}
+
+	addresses, err := getAccountAddresses(ctx, grpcConn)
+	if err != nil {
+		return 0, err
+	}
+
+	// not querying balances concurrently on purpose, so the node isn't flooded with requests
+	balances := make([]float64, 0, len(addresses))
+	for _, address := range addresses {
+		balanceResponse, err := bankClient.Balance(ctx, &banktypes.QueryBalanceRequest{
+			Address: address,
+			Denom:   bondDenom,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if balanceResponse.Balance == nil {
+			continue
+		}
+
+		balance, err := strconv.ParseFloat(balanceResponse.Balance.Amount.String(), 64)
+		if err != nil {
+			return 0, err
+		}
+
+		balances = append(balances, balance)
+	}
+
+	sort.Sort(sort.Reverse(sort.Float64Slice(balances)))
+	if len(balances) > ConcentrationTopN {
+		balances = balances[:ConcentrationTopN]
+	}
+
+	var topBalances float64
+	for _, balance := range balances {
+		topBalances += balance
+	}
+
+	return topBalances / supply, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+type denomInfo struct {
+	Display     string
+	Coefficient float64
+}
+
+// DenomInfos maps the denoms coins are returned in by the chain (like "uatom")
+// to the denom and coefficient they should be displayed with (like "atom" and 1000000).
+var DenomInfos = map[string]denomInfo{}
+
+// setDenomInfos should be called after setDenom, as the staking denom is always
+// displayed the way it's configured with --denom and --denom-coefficient.
+func setDenomInfos(grpcConn *grpc.ClientConn) {
+	metadataCtx, cancelMetadata := getStartupQueryContext()
+	defer cancelMetadata()
+
+	bankClient := banktypes.NewQueryClient(grpcConn)
+	denoms, err := bankClient.DenomsMetadata(
+		metadataCtx,
+		&banktypes.QueryDenomsMetadataRequest{},
+	)
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not get denoms metadata, other denoms would be displayed as is")
+	} else {
+		for _, metadata := range denoms.Metadatas {
+			if info, found := getDisplayDenomInfo(metadata); found {
+				DenomInfos[metadata.Base] = info
+			}
+		}
+	}
+
+	paramsCtx, cancelParams := getStartupQueryContext()
+	defer cancelParams()
+
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	params, err := stakingClient.Params(
+		paramsCtx,
+		&stakingtypes.QueryParamsRequest{},
+	)
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not get staking denom")
+	} else {
+		DenomInfos[params.Params.BondDenom] = denomInfo{
+			Display:     Denom,
+			Coefficient: DenomCoefficient,
+		}
+	}
+
+	for denom, info := range DenomInfos {
+		log.Debug().
+			Str("denom", denom).
+			Str("display", info.Display).
+			Float64("coefficient", info.Coefficient).
+			Msg("Got denom display info")
+	}
+}
+
+// getDenomMetadata returns the metadata the denom belongs to. The chain can have multiple
+// metadatas, and the one we need isn't necessarily the first one, so the one with
+// the same base or display denom is preferred, then the one having the denom as one of its units.
+// If the denom is not set or not found, the first metadata is returned.
+func getDenomMetadata(metadatas []banktypes.Metadata, denom string) banktypes.Metadata {
+	if denom == "" {
+		return metadatas[0]
+	}
+
+	for _, metadata := range metadatas {
+		if metadata.Base == denom || metadata.Display == denom {
+			return metadata
+		}
+	}
+
+	for _, metadata := range metadatas {
+		for _, unit := range metadata.DenomUnits {
+			if unit.Denom == denom {
+				return metadata
+			}
+		}
+	}
+
+	return metadatas[0]
+}
+
+// getDisplayDenomInfo returns the denom unit the metadata says to display the amounts in.
+// The metadata can have multiple units (like uatom, matom and atom), so we can't
+// just take the one with the largest exponent.
+func getDisplayDenomInfo(metadata banktypes.Metadata) (denomInfo, bool) {
+	for _, unit := range metadata.DenomUnits {
+		if unit.Denom == metadata.Display {
+			return denomInfo{
+				Display:     unit.Denom,
+				Coefficient: getDenomCoefficient(metadata, unit.Exponent),
+			}, true
+		}
+	}
+
+	return denomInfo{}, false
+}
+
+// convertCoin converts the amount in the chain denom into the display denom.
+// If there's no display info for the denom, it's returned as is and counted.
+func convertCoin(denom string, amount float64) (string, float64) {
+	if _, found := DenomInfos[denom]; !found {
+		exporterDenomUnmatchedCounter.With(prometheus.Labels{
+			"denom": denom,
+		}).Inc()
+	}
+
+	return convertCoinUncounted(denom, amount)
+}
+
+// convertCoinUncounted is convertCoin which doesn't count the denoms without display info.
+// It should be used for the amounts of the coins already converted with convertCoin
+// during the same scrape, so the coin isn't counted twice.
+func convertCoinUncounted(denom string, amount float64) (string, float64) {
+	info, found := DenomInfos[denom]
+	if !found {
+		return denom, amount
+	}
+
+	return info.Display, amount / info.Coefficient
+}
+
+// getDenomCoefficient returns what the amounts in the base denom should be divided by
+// to get them in the unit with the passed exponent. The base unit usually has exponent 0,
+// but if it doesn't, the exponents are counted relative to it, so if the unit exponent
+// is smaller than the base one, the coefficient is fractional.
+func getDenomCoefficient(metadata banktypes.Metadata, exponent uint32) float64 {
+	var baseExponent uint32
+	for _, unit := range metadata.DenomUnits {
+		if unit.Denom == metadata.Base {
+			baseExponent = unit.Exponent
+			break
+		}
+	}
+
+	return math.Pow10(int(exponent) - int(baseExponent))
+}
+
+func isRewardsDenomAllowed(denom string) bool {
+	if len(RewardsDenomFilter) == 0 {
+		return true
+	}
+
+	for _, allowedDenom := range RewardsDenomFilter {
+		if allowedDenom == denom {
+			return true
+		}
+	}
+
+	return false
+}
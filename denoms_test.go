@@ -0,0 +1,179 @@
+package main
+
+import (
+	"testing"
+
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGetDisplayDenomInfo(t *testing.T) {
+	units := []*banktypes.DenomUnit{
+		{Denom: "uatom", Exponent: 0},
+		{Denom: "matom", Exponent: 3},
+		{Denom: "atom", Exponent: 6},
+	}
+
+	tests := []struct {
+		name      string
+		display   string
+		want      denomInfo
+		wantFound bool
+	}{
+		{
+			name:      "display unit with the largest exponent",
+			display:   "atom",
+			want:      denomInfo{Display: "atom", Coefficient: 1000000},
+			wantFound: true,
+		},
+		{
+			name:      "display unit in the middle",
+			display:   "matom",
+			want:      denomInfo{Display: "matom", Coefficient: 1000},
+			wantFound: true,
+		},
+		{
+			name:      "display unit is the base one",
+			display:   "uatom",
+			want:      denomInfo{Display: "uatom", Coefficient: 1},
+			wantFound: true,
+		},
+		{
+			name:      "display unit is not in the units",
+			display:   "katom",
+			wantFound: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata := banktypes.Metadata{
+				Base:       "uatom",
+				Display:    test.display,
+				DenomUnits: units,
+			}
+
+			got, found := getDisplayDenomInfo(metadata)
+			if found != test.wantFound {
+				t.Fatalf("got found %v, want %v", found, test.wantFound)
+			}
+
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertCoinUsesDisplayDenom(t *testing.T) {
+	defer func(denomInfos map[string]denomInfo) { DenomInfos = denomInfos }(DenomInfos)
+
+	metadata := banktypes.Metadata{
+		Base:    "uosmo",
+		Display: "osmo",
+		DenomUnits: []*banktypes.DenomUnit{
+			{Denom: "uosmo", Exponent: 0},
+			{Denom: "mosmo", Exponent: 3},
+			{Denom: "osmo", Exponent: 6},
+		},
+	}
+
+	info, found := getDisplayDenomInfo(metadata)
+	if !found {
+		t.Fatalf("display denom info is not found")
+	}
+	DenomInfos = map[string]denomInfo{metadata.Base: info}
+
+	denom, amount := convertCoin("uosmo", 2500000)
+	if denom != "osmo" || amount != 2.5 {
+		t.Errorf("got %v %s, want 2.5 osmo", amount, denom)
+	}
+}
+
+func TestGetDenomCoefficient(t *testing.T) {
+	tests := []struct {
+		name     string
+		units    []*banktypes.DenomUnit
+		exponent uint32
+		want     float64
+	}{
+		{
+			name: "display exponent greater than the base one",
+			units: []*banktypes.DenomUnit{
+				{Denom: "aevmos", Exponent: 0},
+				{Denom: "evmos", Exponent: 18},
+			},
+			exponent: 18,
+			want:     1e18,
+		},
+		{
+			name: "base exponent is not zero",
+			units: []*banktypes.DenomUnit{
+				{Denom: "aevmos", Exponent: 3},
+				{Denom: "evmos", Exponent: 9},
+			},
+			exponent: 9,
+			want:     1000000,
+		},
+		{
+			name: "display exponent smaller than the base one",
+			units: []*banktypes.DenomUnit{
+				{Denom: "aevmos", Exponent: 6},
+				{Denom: "kaevmos", Exponent: 3},
+			},
+			exponent: 3,
+			want:     0.001,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata := banktypes.Metadata{
+				Base:       "aevmos",
+				DenomUnits: test.units,
+			}
+
+			if got := getDenomCoefficient(metadata, test.exponent); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertCoinWithFractionalCoefficient(t *testing.T) {
+	defer func(denomInfos map[string]denomInfo) { DenomInfos = denomInfos }(DenomInfos)
+
+	// the display unit has a smaller exponent than the base one
+	DenomInfos = map[string]denomInfo{
+		"aevmos": {Display: "kaevmos", Coefficient: 0.001},
+	}
+
+	denom, amount := convertCoin("aevmos", 5)
+	if denom != "kaevmos" || amount != 5000 {
+		t.Errorf("got %v %s, want 5000 kaevmos", amount, denom)
+	}
+}
+
+func TestConvertCoinCountsUnmatchedDenomOnce(t *testing.T) {
+	defer func(denomInfos map[string]denomInfo) { DenomInfos = denomInfos }(DenomInfos)
+	DenomInfos = map[string]denomInfo{}
+
+	counter := exporterDenomUnmatchedCounter.With(prometheus.Labels{"denom": "uunknown"})
+	before := testutil.ToFloat64(counter)
+
+	denom, amount := convertCoin("uunknown", 100)
+	if denom != "uunknown" || amount != 100 {
+		t.Errorf("got %v %s, want 100 uunknown", amount, denom)
+	}
+
+	// e.g. the balance delta of the same coin
+	denom, amount = convertCoinUncounted("uunknown", 5)
+	if denom != "uunknown" || amount != 5 {
+		t.Errorf("got %v %s, want 5 uunknown", amount, denom)
+	}
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("got the denom counted %v times, want once", got)
+	}
+}
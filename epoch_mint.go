@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Osmosis-style chains have their own mint module which mints tokens once per epoch
+// instead of every block. It is not a part of cosmos-sdk, so instead of depending
+// on the whole chain codebase we only declare the messages we need here.
+const (
+	epochMintParamsMethod          = "/osmosis.mint.v1beta1.Query/Params"
+	epochMintEpochProvisionsMethod = "/osmosis.mint.v1beta1.Query/EpochProvisions"
+)
+
+var EpochMintAvailable bool
+
+type epochMintParamsRequest struct{}
+
+func (m *epochMintParamsRequest) Reset()         { *m = epochMintParamsRequest{} }
+func (m *epochMintParamsRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochMintParamsRequest) ProtoMessage()    {}
+
+type epochMintDistributionProportions struct {
+	Staking          string `protobuf:"bytes,1,opt,name=staking,proto3"`
+	PoolIncentives   string `protobuf:"bytes,2,opt,name=pool_incentives,json=poolIncentives,proto3"`
+	DeveloperRewards string `protobuf:"bytes,3,opt,name=developer_rewards,json=developerRewards,proto3"`
+	CommunityPool    string `protobuf:"bytes,4,opt,name=community_pool,json=communityPool,proto3"`
+}
+
+func (m *epochMintDistributionProportions) Reset()         { *m = epochMintDistributionProportions{} }
+func (m *epochMintDistributionProportions) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochMintDistributionProportions) ProtoMessage()    {}
+
+type epochMintParams struct {
+	MintDenom               string                            `protobuf:"bytes,1,opt,name=mint_denom,json=mintDenom,proto3"`
+	GenesisEpochProvisions  string                            `protobuf:"bytes,2,opt,name=genesis_epoch_provisions,json=genesisEpochProvisions,proto3"`
+	EpochIdentifier         string                            `protobuf:"bytes,3,opt,name=epoch_identifier,json=epochIdentifier,proto3"`
+	ReductionPeriodInEpochs int64                             `protobuf:"varint,4,opt,name=reduction_period_in_epochs,json=reductionPeriodInEpochs,proto3"`
+	ReductionFactor         string                            `protobuf:"bytes,5,opt,name=reduction_factor,json=reductionFactor,proto3"`
+	DistributionProportions *epochMintDistributionProportions `protobuf:"bytes,6,opt,name=distribution_proportions,json=distributionProportions,proto3"`
+}
+
+func (m *epochMintParams) Reset()         { *m = epochMintParams{} }
+func (m *epochMintParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochMintParams) ProtoMessage()    {}
+
+type epochMintParamsResponse struct {
+	Params *epochMintParams `protobuf:"bytes,1,opt,name=params,proto3"`
+}
+
+func (m *epochMintParamsResponse) Reset()         { *m = epochMintParamsResponse{} }
+func (m *epochMintParamsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochMintParamsResponse) ProtoMessage()    {}
+
+type epochMintEpochProvisionsRequest struct{}
+
+func (m *epochMintEpochProvisionsRequest) Reset()         { *m = epochMintEpochProvisionsRequest{} }
+func (m *epochMintEpochProvisionsRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochMintEpochProvisionsRequest) ProtoMessage()    {}
+
+type epochMintEpochProvisionsResponse struct {
+	EpochProvisions []byte `protobuf:"bytes,1,opt,name=epoch_provisions,json=epochProvisions,proto3"`
+}
+
+func (m *epochMintEpochProvisionsResponse) Reset()         { *m = epochMintEpochProvisionsResponse{} }
+func (m *epochMintEpochProvisionsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochMintEpochProvisionsResponse) ProtoMessage()    {}
+
+func queryEpochMintParams(ctx context.Context, grpcConn *grpc.ClientConn) (*epochMintParams, error) {
+	response := &epochMintParamsResponse{}
+	if err := grpcConn.Invoke(ctx, epochMintParamsMethod, &epochMintParamsRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	if response.Params == nil {
+		return &epochMintParams{}, nil
+	}
+
+	return response.Params, nil
+}
+
+func queryEpochProvisions(ctx context.Context, grpcConn *grpc.ClientConn) (float64, error) {
+	response := &epochMintEpochProvisionsResponse{}
+	if err := grpcConn.Invoke(ctx, epochMintEpochProvisionsMethod, &epochMintEpochProvisionsRequest{}, response); err != nil {
+		return 0, err
+	}
+
+	return parseEpochMintDec(string(response.EpochProvisions))
+}
+
+// parseEpochMintDec converts sdk.Dec in its wire format (an integer with 18 implied
+// decimal places) into a float.
+func parseEpochMintDec(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	var dec sdk.Dec
+	if err := dec.Unmarshal([]byte(value)); err != nil {
+		return 0, err
+	}
+
+	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+	return strconv.ParseFloat(dec.String(), 64)
+}
+
+func setEpochMintAvailable(grpcConn *grpc.ClientConn) {
+	ctx, cancel := getStartupQueryContext()
+	defer cancel()
+
+	_, err := queryEpochProvisions(ctx, grpcConn)
+	if err == nil {
+		log.Info().Msg("Epoch-based mint module detected, will expose epoch provisions")
+		EpochMintAvailable = true
+		return
+	}
+
+	if status.Code(err) == codes.Unimplemented {
+		log.Debug().Msg("Epoch-based mint module is not available")
+	} else {
+		log.Warn().Err(err).Msg("Could not detect epoch-based mint module")
+	}
+}
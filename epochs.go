@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Osmosis-style chains track epochs in a separate module, the mint module only refers
+// to the epoch by its identifier. Same as with the mint module, we only declare
+// the messages we need, including the well-known timestamp and duration ones.
+const epochsInfoMethod = "/osmosis.epochs.v1beta1.Query/EpochInfos"
+
+type epochTimestamp struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos,proto3"`
+}
+
+func (m *epochTimestamp) Reset()         { *m = epochTimestamp{} }
+func (m *epochTimestamp) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochTimestamp) ProtoMessage()    {}
+
+type epochDuration struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos,proto3"`
+}
+
+func (m *epochDuration) Reset()         { *m = epochDuration{} }
+func (m *epochDuration) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochDuration) ProtoMessage()    {}
+
+func getEpochDuration(duration *epochDuration) time.Duration {
+	if duration == nil {
+		return 0
+	}
+
+	return time.Duration(duration.Seconds)*time.Second + time.Duration(duration.Nanos)
+}
+
+type epochInfo struct {
+	Identifier              string          `protobuf:"bytes,1,opt,name=identifier,proto3"`
+	StartTime               *epochTimestamp `protobuf:"bytes,2,opt,name=start_time,json=startTime,proto3"`
+	Duration                *epochDuration  `protobuf:"bytes,3,opt,name=duration,proto3"`
+	CurrentEpoch            int64           `protobuf:"varint,4,opt,name=current_epoch,json=currentEpoch,proto3"`
+	CurrentEpochStartTime   *epochTimestamp `protobuf:"bytes,5,opt,name=current_epoch_start_time,json=currentEpochStartTime,proto3"`
+	EpochCountingStarted    bool            `protobuf:"varint,6,opt,name=epoch_counting_started,json=epochCountingStarted,proto3"`
+	CurrentEpochStartHeight int64           `protobuf:"varint,8,opt,name=current_epoch_start_height,json=currentEpochStartHeight,proto3"`
+}
+
+func (m *epochInfo) Reset()         { *m = epochInfo{} }
+func (m *epochInfo) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochInfo) ProtoMessage()    {}
+
+type epochsInfoRequest struct{}
+
+func (m *epochsInfoRequest) Reset()         { *m = epochsInfoRequest{} }
+func (m *epochsInfoRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochsInfoRequest) ProtoMessage()    {}
+
+type epochsInfoResponse struct {
+	Epochs []*epochInfo `protobuf:"bytes,1,rep,name=epochs,proto3"`
+}
+
+func (m *epochsInfoResponse) Reset()         { *m = epochsInfoResponse{} }
+func (m *epochsInfoResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*epochsInfoResponse) ProtoMessage()    {}
+
+func queryEpochInfo(ctx context.Context, grpcConn *grpc.ClientConn, identifier string) (*epochInfo, error) {
+	response := &epochsInfoResponse{}
+	if err := grpcConn.Invoke(ctx, epochsInfoMethod, &epochsInfoRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	for _, epoch := range response.Epochs {
+		if epoch != nil && epoch.Identifier == identifier {
+			return epoch, nil
+		}
+	}
+
+	return nil, fmt.Errorf("epoch %q not found", identifier)
+}
+
+// getEpochEndTime returns when the current epoch ends, which is when the next one starts.
+func getEpochEndTime(epoch *epochInfo) (time.Time, error) {
+	if epoch.CurrentEpochStartTime == nil || epoch.Duration == nil {
+		return time.Time{}, fmt.Errorf("epoch %q has no start time or duration", epoch.Identifier)
+	}
+
+	startTime := time.Unix(epoch.CurrentEpochStartTime.Seconds, int64(epoch.CurrentEpochStartTime.Nanos))
+	return startTime.Add(getEpochDuration(epoch.Duration)), nil
+}
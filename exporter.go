@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+)
+
+// all the metrics are declared with this prefix, and it's replaced with --metrics-prefix when they're served
+const defaultMetricsPrefix = "cosmos"
+
+// ExporterRegistry holds the metrics about the exporter itself rather than the chain.
+// It's served on every endpoint alongside the handler's own registry.
+var ExporterRegistry = prometheus.NewRegistry()
+
+var (
+	StartTime        = time.Now()
+	ConfigLoadedTime time.Time
+
+	exporterBuildInfoGauge        *prometheus.GaugeVec
+	exporterStartTimeGauge        prometheus.Gauge
+	exporterConfigLoadedTimeGauge prometheus.Gauge
+	exporterDenomUnmatchedCounter *prometheus.CounterVec
+
+	exporterConsensusKeyDecodeErrorsCounter prometheus.Counter
+	exporterQueryTimeoutsCounter            *prometheus.CounterVec
+	exporterCacheHitsCounter                *prometheus.CounterVec
+	exporterScrapeDurationHistogram         *prometheus.HistogramVec
+	exporterScrapeErrorsCounter             *prometheus.CounterVec
+
+	exporterChainIDMismatchGauge prometheus.Gauge
+	exporterBechPrefixOKGauge    prometheus.Gauge
+
+	// assuming the node is up, as the exporter doesn't start otherwise
+	grpcUp              float64 = 1
+	grpcUpMutex         sync.Mutex
+	exporterGRPCUpGauge prometheus.Gauge
+
+	exporterRPCActiveGaugeVec          *prometheus.GaugeVec
+	exporterPossibleTruncationGaugeVec *prometheus.GaugeVec
+)
+
+// initExporterMetrics should be called once on startup. The exporter metrics don't have
+// the const labels themselves, they are added when the metrics are gathered, so the metrics
+// don't have to be re-created and the counters don't start over when the chain-id changes.
+func initExporterMetrics() {
+	exporterBuildInfoGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_build_info",
+			Help: "Build info of the exporter, value is always 1",
+		},
+		[]string{"version", "commit", "date", "goversion"},
+	)
+
+	exporterStartTimeGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_start_time_seconds",
+			Help: "Unix timestamp of the exporter start",
+		},
+	)
+
+	exporterConfigLoadedTimeGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_config_loaded_time_seconds",
+			Help: "Unix timestamp of the last time the exporter config was loaded",
+		},
+	)
+
+	exporterDenomUnmatchedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cosmos_exporter_denom_unmatched_total",
+			Help: "Amount of times a coin was reported in its chain denom as there's no display info for it",
+		},
+		[]string{"denom"},
+	)
+
+	exporterConsensusKeyDecodeErrorsCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cosmos_exporter_consensus_key_decode_errors_total",
+			Help: "Amount of times a validator consensus pubkey could not be decoded",
+		},
+	)
+
+	exporterQueryTimeoutsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cosmos_exporter_query_timeouts_total",
+			Help: "Amount of requests that failed because the node queries took longer than --query-timeout",
+		},
+		[]string{"endpoint"},
+	)
+
+	exporterCacheHitsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cosmos_exporter_cache_hits_total",
+			Help: "Amount of requests served without querying the node, as the response was cached or rendered for a concurrent request",
+		},
+		[]string{"endpoint"},
+	)
+
+	exporterScrapeDurationHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "cosmos_exporter_scrape_duration_seconds",
+			Help: "Time it took to serve the endpoint",
+			// scanning the validators set on a big chain can take a minute
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+		},
+		[]string{"handler"},
+	)
+
+	exporterScrapeErrorsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cosmos_exporter_scrape_errors_total",
+			Help: "Amount of requests during which at least one gRPC query to the node failed",
+		},
+		[]string{"handler"},
+	)
+
+	exporterChainIDMismatchGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_chain_id_mismatch",
+			Help: "1 if the gRPC node and Tendermint RPC report different chain-ids, 0 if no",
+		},
+	)
+
+	exporterBechPrefixOKGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_bech_prefix_ok",
+			Help: "1 if the configured validator prefix matches the chain's validators, 0 if no",
+		},
+	)
+
+	exporterPossibleTruncationGaugeVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_possible_truncation",
+			Help: "1 if some of the items of the last query were probably cut off, because it had as many items as --limit or too many pages, 0 if no",
+		},
+		[]string{"query"},
+	)
+
+	exporterGRPCUpGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_grpc_up",
+			Help: "1 if the last gRPC query reached the node, 0 if it was unreachable even after the retries",
+		},
+	)
+
+	exporterRPCActiveGaugeVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cosmos_exporter_rpc_active",
+			Help: "1 if the Tendermint RPC endpoint is the one the requests are sent to first, 0 if no",
+		},
+		[]string{"endpoint"},
+	)
+
+	ExporterRegistry.MustRegister(exporterBuildInfoGauge)
+	ExporterRegistry.MustRegister(exporterRPCActiveGaugeVec)
+	ExporterRegistry.MustRegister(exporterStartTimeGauge)
+	ExporterRegistry.MustRegister(exporterConfigLoadedTimeGauge)
+	ExporterRegistry.MustRegister(exporterDenomUnmatchedCounter)
+	ExporterRegistry.MustRegister(exporterConsensusKeyDecodeErrorsCounter)
+	ExporterRegistry.MustRegister(exporterQueryTimeoutsCounter)
+	ExporterRegistry.MustRegister(exporterCacheHitsCounter)
+	ExporterRegistry.MustRegister(exporterScrapeDurationHistogram)
+	ExporterRegistry.MustRegister(exporterScrapeErrorsCounter)
+	ExporterRegistry.MustRegister(exporterChainIDMismatchGauge)
+	ExporterRegistry.MustRegister(exporterBechPrefixOKGauge)
+	ExporterRegistry.MustRegister(exporterPossibleTruncationGaugeVec)
+	ExporterRegistry.MustRegister(exporterGRPCUpGauge)
+
+	exporterBuildInfoGauge.With(prometheus.Labels{
+		"version":   version,
+		"commit":    commit,
+		"date":      date,
+		"goversion": runtime.Version(),
+	}).Set(1)
+	exporterStartTimeGauge.Set(float64(StartTime.Unix()))
+	exporterConfigLoadedTimeGauge.Set(float64(ConfigLoadedTime.Unix()))
+	// assuming the prefixes are fine until proven otherwise
+	exporterBechPrefixOKGauge.Set(1)
+
+	grpcUpMutex.Lock()
+	exporterGRPCUpGauge.Set(grpcUp)
+	grpcUpMutex.Unlock()
+
+	setTendermintEndpointActive(int(atomic.LoadInt32(&activeTendermintEndpoint)))
+}
+
+func setChainIDMismatch(mismatch bool) {
+	if mismatch {
+		exporterChainIDMismatchGauge.Set(1)
+	} else {
+		exporterChainIDMismatchGauge.Set(0)
+	}
+}
+
+func setBechPrefixOK(ok bool) {
+	if ok {
+		exporterBechPrefixOKGauge.Set(1)
+	} else {
+		exporterBechPrefixOKGauge.Set(0)
+	}
+}
+
+func setGRPCUp(up bool) {
+	grpcUpMutex.Lock()
+	defer grpcUpMutex.Unlock()
+
+	if up {
+		grpcUp = 1
+	} else {
+		grpcUp = 0
+	}
+
+	// the queries made before the exporter metrics are initialized are not reported
+	if exporterGRPCUpGauge != nil {
+		exporterGRPCUpGauge.Set(grpcUp)
+	}
+}
+
+// setTendermintEndpointActive reports the endpoint with this index as the active one.
+func setTendermintEndpointActive(active int) {
+	// the requests made before the exporter metrics are initialized are not reported
+	if exporterRPCActiveGaugeVec == nil {
+		return
+	}
+
+	for index, endpoint := range tendermintEndpoints {
+		var value float64
+		if index == active {
+			value = 1
+		}
+
+		exporterRPCActiveGaugeVec.With(prometheus.Labels{"endpoint": endpoint.Address}).Set(value)
+	}
+}
+
+// checkPossibleTruncation should be called with the amount of items returned by the queries
+// that don't follow the pagination. If it's equal to --limit, there are probably more of them.
+func checkPossibleTruncation(query string, count int) {
+	truncated := Limit > 0 && uint64(count) >= Limit
+	if truncated {
+		log.Warn().
+			Str("query", query).
+			Uint64("--limit", Limit).
+			Msg("Query returned as many items as --limit, the results are probably truncated. Consider increasing --limit")
+	}
+
+	setPossibleTruncation(query, truncated)
+}
+
+// setPossibleTruncation reports whether some items of the query were probably cut off.
+func setPossibleTruncation(query string, truncated bool) {
+	var value float64
+	if truncated {
+		value = 1
+	}
+
+	exporterPossibleTruncationGaugeVec.With(prometheus.Labels{"query": query}).Set(value)
+}
+
+// truncateLabelValue cuts the value to maxLength characters, so free-form texts
+// don't make the label values (and the series) huge.
+func truncateLabelValue(value string, maxLength int) string {
+	runes := []rune(value)
+	if len(runes) <= maxLength {
+		return value
+	}
+
+	return string(runes[:maxLength]) + "..."
+}
+
+// handleQueryTimeout should be called by the handlers before writing the metrics.
+// If the queries didn't finish in time, the metrics are incomplete, so it's better to fail
+// the scrape than to return them. If the client is gone, there's no one to write them to.
+func handleQueryTimeout(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Context().Err() {
+	case context.DeadlineExceeded:
+		zerolog.Ctx(r.Context()).Error().
+			Str("endpoint", r.URL.Path).
+			Dur("--query-timeout", QueryTimeout).
+			Msg("Queries took too long, consider increasing --query-timeout")
+		exporterQueryTimeoutsCounter.With(prometheus.Labels{
+			"endpoint": r.URL.Path,
+		}).Inc()
+		http.Error(w, "Queries to the node took too long", http.StatusGatewayTimeout)
+		return true
+	case context.Canceled:
+		zerolog.Ctx(r.Context()).Debug().
+			Str("endpoint", r.URL.Path).
+			Msg("Request was cancelled by the client")
+		return true
+	default:
+		return false
+	}
+}
+
+type scrapeErrorsKey struct{}
+
+// withScrapeErrors returns the context the gRPC queries of the request should be made with,
+// so the queries that failed are counted, and the counter itself.
+func withScrapeErrors(ctx context.Context) (context.Context, *int32) {
+	var errors int32
+	return context.WithValue(ctx, scrapeErrorsKey{}, &errors), &errors
+}
+
+// markScrapeError is called by the gRPC interceptor on every failed query.
+// The queries made outside of a request, like on startup, are not counted.
+func markScrapeError(ctx context.Context) {
+	if errors, ok := ctx.Value(scrapeErrorsKey{}).(*int32); ok {
+		atomic.AddInt32(errors, 1)
+	}
+}
+
+// observeScrape should be called after the handler has finished, with the counter from withScrapeErrors.
+func observeScrape(handler string, requestStart time.Time, errors *int32) {
+	exporterScrapeDurationHistogram.With(prometheus.Labels{
+		"handler": handler,
+	}).Observe(time.Since(requestStart).Seconds())
+
+	if atomic.LoadInt32(errors) > 0 {
+		exporterScrapeErrorsCounter.With(prometheus.Labels{"handler": handler}).Inc()
+	}
+}
+
+// getGatherer returns what the handlers should serve: their own metrics and the exporter ones,
+// with the prefix replaced with --metrics-prefix.
+func getGatherer(registries ...*prometheus.Registry) prometheus.Gatherer {
+	gatherers := prometheus.Gatherers{constLabelsGatherer{ExporterRegistry}}
+	for _, registry := range registries {
+		gatherers = append(gatherers, registry)
+	}
+
+	return prefixedGatherer{gatherers}
+}
+
+type prefixedGatherer struct {
+	prometheus.Gatherer
+}
+
+func (g prefixedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	if MetricsPrefix == defaultMetricsPrefix {
+		return families, err
+	}
+
+	// the families are created on each gather, so it's safe to modify them
+	for _, family := range families {
+		name := family.GetName()
+		if strings.HasPrefix(name, defaultMetricsPrefix+"_") {
+			prefixedName := MetricsPrefix + strings.TrimPrefix(name, defaultMetricsPrefix)
+			family.Name = &prefixedName
+		}
+	}
+
+	return families, err
+}
+
+// constLabelsGatherer adds the current const labels to the gathered metrics.
+type constLabelsGatherer struct {
+	prometheus.Gatherer
+}
+
+func (g constLabelsGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	constLabels := getConstLabels()
+
+	// the families are created on each gather, so it's safe to modify them
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			existing := make(map[string]bool, len(metric.Label))
+			for _, label := range metric.Label {
+				existing[label.GetName()] = true
+			}
+
+			for name, value := range constLabels {
+				// a metric can't have the same label twice, so its own one wins
+				if existing[name] {
+					continue
+				}
+
+				name, value := name, value
+				metric.Label = append(metric.Label, &dto.LabelPair{Name: &name, Value: &value})
+			}
+
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+	}
+
+	return families, err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMain(m *testing.M) {
+	// the handlers helpers report their errors with the exporter metrics
+	initExporterMetrics()
+	os.Exit(m.Run())
+}
+
+func TestConstLabelsGathererKeepsCountersOnChainIDChange(t *testing.T) {
+	defer func(chainID string, labels map[string]string) {
+		ChainID, ConstLabels = chainID, labels
+	}(ChainID, ConstLabels)
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cosmos_exporter_query_timeouts_total",
+			Help: "Amount of requests that failed because of timeouts",
+		},
+		[]string{"endpoint"},
+	)
+	registry.MustRegister(counter)
+
+	tests := []struct {
+		chainID string
+		want    float64
+	}{
+		{chainID: "cosmoshub-3", want: 1},
+		{chainID: "cosmoshub-4", want: 2},
+	}
+
+	for _, test := range tests {
+		updateChainID(test.chainID)
+		counter.With(prometheus.Labels{"endpoint": "/metrics/general"}).Inc()
+
+		families, err := constLabelsGatherer{registry}.Gather()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		metric := families[0].GetMetric()[0]
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+
+		if labels["chain_id"] != test.chainID || labels["endpoint"] != "/metrics/general" {
+			t.Errorf("got labels %v, want chain_id %s", labels, test.chainID)
+		}
+
+		if metric.GetCounter().GetValue() != test.want {
+			t.Errorf("got %v, want %v", metric.GetCounter().GetValue(), test.want)
+		}
+	}
+}
+
+func TestConstLabelsGathererKeepsMetricLabels(t *testing.T) {
+	defer func(chainID string, labels map[string]string) {
+		ChainID, ConstLabels = chainID, labels
+	}(ChainID, ConstLabels)
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cosmos_validators_tokens",
+			Help: "Tokens of the Cosmos-based blockchain validator",
+		},
+		[]string{"denom"},
+	)
+	registry.MustRegister(gauge)
+	gauge.With(prometheus.Labels{"denom": "atom"}).Set(1)
+
+	updateChainID("cosmoshub-4")
+	ConstLabels["denom"] = "uatom"
+
+	families, err := constLabelsGatherer{registry}.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	labels := families[0].GetMetric()[0].GetLabel()
+	if len(labels) != 2 {
+		t.Fatalf("got labels %v, want chain_id and denom", labels)
+	}
+
+	for _, label := range labels {
+		if label.GetName() == "denom" && label.GetValue() != "atom" {
+			t.Errorf("got denom %s, want atom", label.GetValue())
+		}
+	}
+}
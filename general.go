@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"math"
 	"net/http"
 	"strconv"
 	"sync"
@@ -11,32 +12,57 @@ import (
 	distributiontypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
 	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// exemplarBlockHashLength is how many first characters of the block hash are used as an exemplar.
+const exemplarBlockHashLength = 16
+
 func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
 	requestStart := time.Now()
 
-	sublogger := log.With().
-		Str("request-id", uuid.New().String()).
-		Logger()
+	sublogger := zerolog.Ctx(r.Context())
+
+	registry := getGeneralRegistry(r.Context(), grpcConn)
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/general").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
+
+// getGeneralRegistry queries the chain-wide metrics and returns the registry with them,
+// so they can be served both on /metrics/general and on /metrics.
+func getGeneralRegistry(ctx context.Context, grpcConn *grpc.ClientConn) *prometheus.Registry {
+	sublogger := zerolog.Ctx(ctx)
 
 	generalBondedTokensGauge := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_general_bonded_tokens",
-			Help:        "Bonded tokens",
-			ConstLabels: ConstLabels,
+			Help:        "Bonded tokens, in the display denom",
+			ConstLabels: getConstLabels(),
 		},
 	)
 
 	generalNotBondedTokensGauge := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_general_not_bonded_tokens",
-			Help:        "Not bonded tokens",
-			ConstLabels: ConstLabels,
+			Help:        "Not bonded tokens, in the display denom",
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -44,7 +70,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "cosmos_general_community_pool",
 			Help:        "Community pool",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"denom"},
 	)
@@ -53,7 +79,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "cosmos_general_supply_total",
 			Help:        "Total supply",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"denom"},
 	)
@@ -61,8 +87,8 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 	generalInflationGauge := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_general_inflation",
-			Help:        "Total supply",
-			ConstLabels: ConstLabels,
+			Help:        "Inflation",
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -70,11 +96,135 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "cosmos_general_annual_provisions",
 			Help:        "Annual provisions",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"denom"},
 	)
 
+	generalBondedRatioGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_general_bonded_ratio",
+			Help:        "Share of the staking denom supply that is bonded",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	generalEpochProvisionsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_general_epoch_provisions",
+			Help:        "Tokens minted per epoch, for chains with epoch-based minting",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"denom"},
+	)
+
+	nodeAppVersionGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_node_app_version",
+			Help:        "Application version of the node, value is always 1",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"version", "app_protocol_version"},
+	)
+
+	nodeTendermintVersionGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_node_tendermint_version",
+			Help:        "Tendermint version of the node, value is always 1",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"version", "block_protocol_version", "p2p_protocol_version"},
+	)
+
+	latestBlockHeightGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_latest_block_height",
+			Help:        "Latest block height the node has",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	// the same height as a counter, as OpenMetrics only allows exemplars on counters and histograms
+	latestBlockHeightExemplarCounter := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name:        "cosmos_latest_block_height_exemplar_total",
+			Help:        "Latest block height the node has, with the block hash as an exemplar",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	latestBlockTimeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_latest_block_time",
+			Help:        "Unix timestamp of the latest block the node has",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	nodeCatchingUpGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_node_catching_up",
+			Help:        "1 if the node is catching up with the chain, 0 if no",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	blockTimeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_block_time_seconds",
+			Help:        "Time between the two latest blocks, in seconds",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	avgBlockTimeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_avg_block_time_seconds",
+			Help:        "Average time between the latest blocks, in seconds",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	mempoolSizeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_mempool_size",
+			Help:        "Amount of unconfirmed transactions in the node mempool",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	mempoolTotalBytesGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_mempool_total_bytes",
+			Help:        "Total size of unconfirmed transactions in the node mempool, in bytes",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	nextRewardDistributionGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_next_reward_distribution_seconds",
+			Help:        "Seconds until the next staking rewards distribution, 0 if the rewards are distributed every block",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	supplyTopNShareGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_supply_top_n_share",
+			Help:        "Share of the staking denom supply held by the --concentration-top-n accounts with the largest liquid balance",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	stakingUnbondingEntriesGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_staking_unbonding_entries_total",
+			Help:        "Amount of unbonding delegation entries of all the validators, only reported if --scan-unbonding-entries is set",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(generalBondedTokensGauge)
 	registry.MustRegister(generalNotBondedTokensGauge)
@@ -82,6 +232,25 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 	registry.MustRegister(generalSupplyTotalGauge)
 	registry.MustRegister(generalInflationGauge)
 	registry.MustRegister(generalAnnualProvisions)
+	registry.MustRegister(generalBondedRatioGauge)
+	registry.MustRegister(generalEpochProvisionsGauge)
+	registry.MustRegister(nodeAppVersionGauge)
+	registry.MustRegister(nodeTendermintVersionGauge)
+	registry.MustRegister(latestBlockHeightGauge)
+	registry.MustRegister(latestBlockHeightExemplarCounter)
+	registry.MustRegister(latestBlockTimeGauge)
+	registry.MustRegister(nodeCatchingUpGauge)
+	registry.MustRegister(blockTimeGauge)
+	registry.MustRegister(avgBlockTimeGauge)
+	registry.MustRegister(mempoolSizeGauge)
+	registry.MustRegister(mempoolTotalBytesGauge)
+	registry.MustRegister(nextRewardDistributionGauge)
+	if ScanUnbondingEntries {
+		registry.MustRegister(stakingUnbondingEntriesGauge)
+	}
+	if ConcentrationTopN > 0 {
+		registry.MustRegister(supplyTopNShareGauge)
+	}
 
 	var wg sync.WaitGroup
 
@@ -93,7 +262,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 
 		stakingClient := stakingtypes.NewQueryClient(grpcConn)
 		response, err := stakingClient.Pool(
-			context.Background(),
+			ctx,
 			&stakingtypes.QueryPoolRequest{},
 		)
 		if err != nil {
@@ -105,8 +274,23 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 			Float64("request-time", time.Since(queryStart).Seconds()).
 			Msg("Finished querying staking pool")
 
-		generalBondedTokensGauge.Set(float64(response.Pool.BondedTokens.Int64()))
-		generalNotBondedTokensGauge.Set(float64(response.Pool.NotBondedTokens.Int64()))
+		// the pool is always in the staking denom, so --denom-coefficient applies.
+		// Not using .Int64() as the amounts in the base denom can overflow it
+		if value, err := strconv.ParseFloat(response.Pool.BondedTokens.String(), 64); err != nil {
+			sublogger.Error().
+				Err(err).
+				Msg("Could not get bonded tokens")
+		} else {
+			generalBondedTokensGauge.Set(value / DenomCoefficient)
+		}
+
+		if value, err := strconv.ParseFloat(response.Pool.NotBondedTokens.String(), 64); err != nil {
+			sublogger.Error().
+				Err(err).
+				Msg("Could not get not bonded tokens")
+		} else {
+			generalNotBondedTokensGauge.Set(value / DenomCoefficient)
+		}
 	}()
 
 	wg.Add(1)
@@ -117,7 +301,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 
 		distributionClient := distributiontypes.NewQueryClient(grpcConn)
 		response, err := distributionClient.CommunityPool(
-			context.Background(),
+			ctx,
 			&distributiontypes.QueryCommunityPoolRequest{},
 		)
 		if err != nil {
@@ -135,9 +319,10 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 					Err(err).
 					Msg("Could not get community pool coin")
 			} else {
+				denom, amount := convertCoin(coin.Denom, value)
 				generalCommunityPoolGauge.With(prometheus.Labels{
-					"denom": Denom,
-				}).Set(value / DenomCoefficient)
+					"denom": denom,
+				}).Set(amount)
 			}
 		}
 	}()
@@ -150,7 +335,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 
 		bankClient := banktypes.NewQueryClient(grpcConn)
 		response, err := bankClient.TotalSupply(
-			context.Background(),
+			ctx,
 			&banktypes.QueryTotalSupplyRequest{},
 		)
 		if err != nil {
@@ -168,9 +353,10 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 					Err(err).
 					Msg("Could not get total supply")
 			} else {
+				denom, amount := convertCoin(coin.Denom, value)
 				generalSupplyTotalGauge.With(prometheus.Labels{
-					"denom": Denom,
-				}).Set(value / DenomCoefficient)
+					"denom": denom,
+				}).Set(amount)
 			}
 		}
 	}()
@@ -183,10 +369,14 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 
 		mintClient := minttypes.NewQueryClient(grpcConn)
 		response, err := mintClient.Inflation(
-			context.Background(),
+			ctx,
 			&minttypes.QueryInflationRequest{},
 		)
-		if err != nil {
+		// chains like Osmosis have their own mint module without this query
+		if status.Code(err) == codes.Unimplemented {
+			sublogger.Debug().Msg("Mint module doesn't support inflation query, not querying it")
+			return
+		} else if err != nil {
 			sublogger.Error().Err(err).Msg("Could not get inflation")
 			return
 		}
@@ -212,10 +402,13 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 
 		mintClient := minttypes.NewQueryClient(grpcConn)
 		response, err := mintClient.AnnualProvisions(
-			context.Background(),
+			ctx,
 			&minttypes.QueryAnnualProvisionsRequest{},
 		)
-		if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			sublogger.Debug().Msg("Mint module doesn't support annual provisions query, not querying it")
+			return
+		} else if err != nil {
 			sublogger.Error().Err(err).Msg("Could not get annual provisions")
 			return
 		}
@@ -235,13 +428,293 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		}
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sublogger.Debug().Msg("Started querying bonded ratio")
+		queryStart := time.Now()
+
+		bondedRatio, err := getBondedRatio(ctx, grpcConn)
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not get bonded ratio")
+			return
+		}
+
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying bonded ratio")
+
+		generalBondedRatioGauge.Set(bondedRatio)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sublogger.Debug().Msg("Started querying node versions")
+		queryStart := time.Now()
+
+		client, err := newTendermintClient()
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not create Tendermint client")
+			return
+		}
+
+		status, err := client.Status(ctx)
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not query Tendermint status")
+			return
+		}
+
+		abciInfo, err := client.ABCIInfo(ctx)
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not query ABCI info")
+			return
+		}
+
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying node versions")
+
+		nodeAppVersionGauge.With(prometheus.Labels{
+			"version":              abciInfo.Response.Version,
+			"app_protocol_version": strconv.FormatUint(status.NodeInfo.ProtocolVersion.App, 10),
+		}).Set(1)
+
+		nodeTendermintVersionGauge.With(prometheus.Labels{
+			"version":                status.NodeInfo.Version,
+			"block_protocol_version": strconv.FormatUint(status.NodeInfo.ProtocolVersion.Block, 10),
+			"p2p_protocol_version":   strconv.FormatUint(status.NodeInfo.ProtocolVersion.P2P, 10),
+		}).Set(1)
+
+		// exemplars are only returned if the client negotiates OpenMetrics format.
+		// Exemplar labels are limited to 64 characters in total, so the full hash doesn't fit
+		blockHash := status.SyncInfo.LatestBlockHash.String()
+		if len(blockHash) > exemplarBlockHashLength {
+			blockHash = blockHash[:exemplarBlockHashLength]
+		}
+
+		latestBlockHeightGauge.Set(float64(status.SyncInfo.LatestBlockHeight))
+		latestBlockHeightExemplarCounter.(prometheus.ExemplarAdder).AddWithExemplar(
+			float64(status.SyncInfo.LatestBlockHeight),
+			prometheus.Labels{"block_hash": blockHash},
+		)
+
+		latestBlockTimeGauge.Set(float64(status.SyncInfo.LatestBlockTime.Unix()))
+
+		if status.SyncInfo.CatchingUp {
+			nodeCatchingUpGauge.Set(1)
+		} else {
+			nodeCatchingUpGauge.Set(0)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sublogger.Debug().Msg("Started querying block time")
+		queryStart := time.Now()
+
+		avgBlockTime, blockTime, err := getBlockTimes(ctx)
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not get block time")
+			return
+		}
+
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying block time")
+
+		avgBlockTimeGauge.Set(avgBlockTime.Seconds())
+		blockTimeGauge.Set(blockTime.Seconds())
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sublogger.Debug().Msg("Started querying mempool")
+		queryStart := time.Now()
+
+		client, err := newTendermintClient()
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not create Tendermint client")
+			return
+		}
+
+		response, err := client.NumUnconfirmedTxs(ctx)
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not get mempool")
+			return
+		}
+
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying mempool")
+
+		mempoolSizeGauge.Set(float64(response.Total))
+		mempoolTotalBytesGauge.Set(float64(response.TotalBytes))
+	}()
+
+	if EpochMintAvailable {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().Msg("Started querying epoch provisions")
+			queryStart := time.Now()
+
+			value, err := queryEpochProvisions(ctx, grpcConn)
+			if err != nil {
+				sublogger.Error().Err(err).Msg("Could not get epoch provisions")
+				return
+			}
+
+			sublogger.Debug().
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying epoch provisions")
+
+			generalEpochProvisionsGauge.With(prometheus.Labels{
+				"denom": Denom,
+			}).Set(value / DenomCoefficient)
+		}()
+	}
+
+	if EpochMintAvailable {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().Msg("Started querying epoch info")
+			queryStart := time.Now()
+
+			params, err := queryEpochMintParams(ctx, grpcConn)
+			if err != nil {
+				sublogger.Error().Err(err).Msg("Could not get epoch mint params")
+				return
+			}
+
+			epoch, err := queryEpochInfo(ctx, grpcConn, params.EpochIdentifier)
+			if err != nil {
+				sublogger.Error().Err(err).Msg("Could not get epoch info")
+				return
+			}
+
+			sublogger.Debug().
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying epoch info")
+
+			epochEndTime, err := getEpochEndTime(epoch)
+			if err != nil {
+				sublogger.Error().Err(err).Msg("Could not get epoch end time")
+				return
+			}
+
+			// the epoch end is only processed in the first block after it, so it might be slightly in the past
+			nextRewardDistributionGauge.Set(math.Max(time.Until(epochEndTime).Seconds(), 0))
+		}()
+	} else {
+		// without the epoch-based mint module rewards are distributed every block
+		nextRewardDistributionGauge.Set(0)
+	}
+
+	if ScanUnbondingEntries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().Msg("Started querying unbonding entries")
+			queryStart := time.Now()
+
+			entries, err := getUnbondingEntriesCount(ctx, grpcConn)
+			if err != nil {
+				sublogger.Error().Err(err).Msg("Could not get unbonding entries")
+				return
+			}
+
+			sublogger.Debug().
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying unbonding entries")
+
+			stakingUnbondingEntriesGauge.Set(float64(entries))
+		}()
+	}
+
+	if ConcentrationTopN > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().Msg("Started querying top accounts supply share")
+			queryStart := time.Now()
+
+			share, err := getTopNSupplyShare(ctx, grpcConn)
+			if err != nil {
+				sublogger.Error().Err(err).Msg("Could not get top accounts supply share")
+				return
+			}
+
+			sublogger.Debug().
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying top accounts supply share")
+
+			supplyTopNShareGauge.Set(share)
+		}()
+	}
+
 	wg.Wait()
 
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
-	sublogger.Info().
-		Str("method", "GET").
-		Str("endpoint", "/metrics/general").
-		Float64("request-time", time.Since(requestStart).Seconds()).
-		Msg("Request processed")
+	return registry
+}
+
+// getUnbondingEntriesCount queries the unbonding delegations of every validator one by one,
+// which can take a while on chains with a lot of validators. The validators are not queried
+// concurrently on purpose, so the node isn't flooded with requests.
+func getUnbondingEntriesCount(ctx context.Context, grpcConn *grpc.ClientConn) (int, error) {
+	validators, err := queryValidators(ctx, grpcConn)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := 0
+
+	for _, validator := range validators {
+		unbondings, err := queryValidatorUnbondingDelegations(ctx, grpcConn, validator.OperatorAddress)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, unbonding := range unbondings {
+			entries += len(unbonding.Entries)
+		}
+	}
+
+	return entries, nil
+}
+
+// getBondedRatio returns the share of the staking denom supply that is bonded,
+// the same way the mint module calculates it to adjust the inflation.
+func getBondedRatio(ctx context.Context, grpcConn *grpc.ClientConn) (float64, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	paramsResponse, err := stakingClient.Params(ctx, &stakingtypes.QueryParamsRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	poolResponse, err := stakingClient.Pool(ctx, &stakingtypes.QueryPoolRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	bankClient := banktypes.NewQueryClient(grpcConn)
+	supplyResponse, err := bankClient.SupplyOf(ctx, &banktypes.QuerySupplyOfRequest{
+		Denom: paramsResponse.Params.BondDenom,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if !supplyResponse.Amount.Amount.IsPositive() {
+		return 0, nil
+	}
+
+	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+	return strconv.ParseFloat(
+		poolResponse.Pool.BondedTokens.ToDec().Quo(supplyResponse.Amount.Amount.ToDec()).String(),
+		64,
+	)
 }
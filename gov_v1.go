@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	"github.com/gogo/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The SDK we depend on only has gov v1beta1, and newer chains may only serve gov v1,
+// so same as with the epochs, we only declare the v1 messages we need.
+const (
+	govV1ProposalsMethod       = "/cosmos.gov.v1.Query/Proposals"
+	govV1ExecLegacyContentType = "/cosmos.gov.v1.MsgExecLegacyContent"
+	proposalStatusVotingPeriod = 2
+)
+
+type govV1Any struct {
+	TypeUrl string `protobuf:"bytes,1,opt,name=type_url,json=typeUrl,proto3"`
+	Value   []byte `protobuf:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *govV1Any) Reset()         { *m = govV1Any{} }
+func (m *govV1Any) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1Any) ProtoMessage()    {}
+
+type govV1TallyResult struct {
+	YesCount        string `protobuf:"bytes,1,opt,name=yes_count,json=yesCount,proto3"`
+	AbstainCount    string `protobuf:"bytes,2,opt,name=abstain_count,json=abstainCount,proto3"`
+	NoCount         string `protobuf:"bytes,3,opt,name=no_count,json=noCount,proto3"`
+	NoWithVetoCount string `protobuf:"bytes,4,opt,name=no_with_veto_count,json=noWithVetoCount,proto3"`
+}
+
+func (m *govV1TallyResult) Reset()         { *m = govV1TallyResult{} }
+func (m *govV1TallyResult) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1TallyResult) ProtoMessage()    {}
+
+// the timestamps are the same well-known type as the one declared for the epochs
+type govV1Proposal struct {
+	Id               uint64            `protobuf:"varint,1,opt,name=id,proto3"`
+	Messages         []*govV1Any       `protobuf:"bytes,2,rep,name=messages,proto3"`
+	Status           int32             `protobuf:"varint,3,opt,name=status,proto3"`
+	FinalTallyResult *govV1TallyResult `protobuf:"bytes,4,opt,name=final_tally_result,json=finalTallyResult,proto3"`
+	VotingStartTime  *epochTimestamp   `protobuf:"bytes,8,opt,name=voting_start_time,json=votingStartTime,proto3"`
+	VotingEndTime    *epochTimestamp   `protobuf:"bytes,9,opt,name=voting_end_time,json=votingEndTime,proto3"`
+	Title            string            `protobuf:"bytes,11,opt,name=title,proto3"`
+}
+
+func (m *govV1Proposal) Reset()         { *m = govV1Proposal{} }
+func (m *govV1Proposal) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1Proposal) ProtoMessage()    {}
+
+type govV1ProposalsRequest struct {
+	Pagination *querytypes.PageRequest `protobuf:"bytes,4,opt,name=pagination,proto3"`
+}
+
+func (m *govV1ProposalsRequest) Reset()         { *m = govV1ProposalsRequest{} }
+func (m *govV1ProposalsRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1ProposalsRequest) ProtoMessage()    {}
+
+type govV1ProposalsResponse struct {
+	Proposals []*govV1Proposal `protobuf:"bytes,1,rep,name=proposals,proto3"`
+}
+
+func (m *govV1ProposalsResponse) Reset()         { *m = govV1ProposalsResponse{} }
+func (m *govV1ProposalsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1ProposalsResponse) ProtoMessage()    {}
+
+// MsgExecLegacyContent wraps the v1beta1 proposal content into a v1 proposal message.
+type govV1ExecLegacyContent struct {
+	Content *govV1Any `protobuf:"bytes,1,opt,name=content,proto3"`
+}
+
+func (m *govV1ExecLegacyContent) Reset()         { *m = govV1ExecLegacyContent{} }
+func (m *govV1ExecLegacyContent) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1ExecLegacyContent) ProtoMessage()    {}
+
+// All the v1beta1 proposal contents have the title as the first field,
+// so there's no need to know the specific content type to get it.
+type govContentTitle struct {
+	Title string `protobuf:"bytes,1,opt,name=title,proto3"`
+}
+
+func (m *govContentTitle) Reset()         { *m = govContentTitle{} }
+func (m *govContentTitle) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govContentTitle) ProtoMessage()    {}
+
+// proposal is what we need from both gov v1 and v1beta1 proposals.
+type proposal struct {
+	ID              uint64
+	Status          int32
+	Type            string
+	Title           string
+	VotingStartTime time.Time
+	VotingEndTime   time.Time
+	// option -> amount of tokens voted for it, only set for the finished proposals
+	FinalTally map[string]string
+}
+
+// queryProposals queries the proposals with gov v1, falling back to v1beta1 if the node doesn't have it.
+func queryProposals(ctx context.Context, grpcConn *grpc.ClientConn) ([]proposal, error) {
+	v1Response := &govV1ProposalsResponse{}
+	err := grpcConn.Invoke(
+		ctx,
+		govV1ProposalsMethod,
+		&govV1ProposalsRequest{Pagination: &querytypes.PageRequest{Limit: Limit}},
+		v1Response,
+	)
+	if err == nil {
+		proposals := make([]proposal, 0, len(v1Response.Proposals))
+		for _, v1Proposal := range v1Response.Proposals {
+			if v1Proposal != nil {
+				proposals = append(proposals, getGovV1Proposal(v1Proposal))
+			}
+		}
+
+		return proposals, nil
+	} else if status.Code(err) != codes.Unimplemented {
+		return nil, err
+	}
+
+	govClient := govtypes.NewQueryClient(grpcConn)
+	v1beta1Response, err := govClient.Proposals(
+		ctx,
+		&govtypes.QueryProposalsRequest{
+			Pagination: &querytypes.PageRequest{
+				Limit: Limit,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	proposals := make([]proposal, 0, len(v1beta1Response.Proposals))
+	for _, v1beta1Proposal := range v1beta1Response.Proposals {
+		proposals = append(proposals, getGovV1beta1Proposal(v1beta1Proposal))
+	}
+
+	return proposals, nil
+}
+
+func getGovV1Proposal(v1Proposal *govV1Proposal) proposal {
+	result := proposal{
+		ID:     v1Proposal.Id,
+		Status: v1Proposal.Status,
+		Title:  v1Proposal.Title,
+	}
+
+	if v1Proposal.VotingStartTime != nil {
+		result.VotingStartTime = time.Unix(v1Proposal.VotingStartTime.Seconds, int64(v1Proposal.VotingStartTime.Nanos))
+	}
+
+	if v1Proposal.VotingEndTime != nil {
+		result.VotingEndTime = time.Unix(v1Proposal.VotingEndTime.Seconds, int64(v1Proposal.VotingEndTime.Nanos))
+	}
+
+	if v1Proposal.Status != proposalStatusVotingPeriod && v1Proposal.FinalTallyResult != nil {
+		result.FinalTally = map[string]string{
+			"yes":          v1Proposal.FinalTallyResult.YesCount,
+			"no":           v1Proposal.FinalTallyResult.NoCount,
+			"no_with_veto": v1Proposal.FinalTallyResult.NoWithVetoCount,
+			"abstain":      v1Proposal.FinalTallyResult.AbstainCount,
+		}
+	}
+
+	if len(v1Proposal.Messages) == 0 || v1Proposal.Messages[0] == nil {
+		return result
+	}
+
+	// the legacy proposals are more informative by their content type than by the message type,
+	// and before SDK v0.47 the v1 proposals don't have the title, so it's also taken from the content
+	result.Type = v1Proposal.Messages[0].TypeUrl
+	if result.Type == govV1ExecLegacyContentType {
+		legacyContent := &govV1ExecLegacyContent{}
+		if err := proto.Unmarshal(v1Proposal.Messages[0].Value, legacyContent); err == nil && legacyContent.Content != nil {
+			result.Type = legacyContent.Content.TypeUrl
+			if result.Title == "" {
+				result.Title = getGovContentTitle(legacyContent.Content.Value)
+			}
+		}
+	}
+
+	return result
+}
+
+func getGovV1beta1Proposal(v1beta1Proposal govtypes.Proposal) proposal {
+	result := proposal{
+		ID:              v1beta1Proposal.ProposalId,
+		Status:          int32(v1beta1Proposal.Status),
+		VotingStartTime: v1beta1Proposal.VotingStartTime,
+		VotingEndTime:   v1beta1Proposal.VotingEndTime,
+	}
+
+	if v1beta1Proposal.Content != nil {
+		result.Type = v1beta1Proposal.Content.TypeUrl
+		result.Title = getGovContentTitle(v1beta1Proposal.Content.Value)
+	}
+
+	if v1beta1Proposal.Status != govtypes.StatusVotingPeriod {
+		result.FinalTally = map[string]string{
+			"yes":          v1beta1Proposal.FinalTallyResult.Yes.String(),
+			"no":           v1beta1Proposal.FinalTallyResult.No.String(),
+			"no_with_veto": v1beta1Proposal.FinalTallyResult.NoWithVeto.String(),
+			"abstain":      v1beta1Proposal.FinalTallyResult.Abstain.String(),
+		}
+	}
+
+	return result
+}
+
+func getGovContentTitle(content []byte) string {
+	title := &govContentTitle{}
+	if err := proto.Unmarshal(content, title); err != nil {
+		return ""
+	}
+
+	return title.Title
+}
+
+const govV1ParamsMethod = "/cosmos.gov.v1.Query/Params"
+
+// both gov v1 and v1beta1 only return one kind of params per query
+var govParamsTypes = []string{"voting", "deposit", "tallying"}
+
+type govV1ParamsRequest struct {
+	ParamsType string `protobuf:"bytes,1,opt,name=params_type,json=paramsType,proto3"`
+}
+
+func (m *govV1ParamsRequest) Reset()         { *m = govV1ParamsRequest{} }
+func (m *govV1ParamsRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1ParamsRequest) ProtoMessage()    {}
+
+type govV1Coin struct {
+	Denom  string `protobuf:"bytes,1,opt,name=denom,proto3"`
+	Amount string `protobuf:"bytes,2,opt,name=amount,proto3"`
+}
+
+func (m *govV1Coin) Reset()         { *m = govV1Coin{} }
+func (m *govV1Coin) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1Coin) ProtoMessage()    {}
+
+// the durations are the same well-known type as the one declared for the epochs
+type govV1VotingParams struct {
+	VotingPeriod *epochDuration `protobuf:"bytes,1,opt,name=voting_period,json=votingPeriod,proto3"`
+}
+
+func (m *govV1VotingParams) Reset()         { *m = govV1VotingParams{} }
+func (m *govV1VotingParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1VotingParams) ProtoMessage()    {}
+
+type govV1DepositParams struct {
+	MinDeposit       []*govV1Coin   `protobuf:"bytes,1,rep,name=min_deposit,json=minDeposit,proto3"`
+	MaxDepositPeriod *epochDuration `protobuf:"bytes,2,opt,name=max_deposit_period,json=maxDepositPeriod,proto3"`
+}
+
+func (m *govV1DepositParams) Reset()         { *m = govV1DepositParams{} }
+func (m *govV1DepositParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1DepositParams) ProtoMessage()    {}
+
+// unlike v1beta1, v1 returns the decimals as strings
+type govV1TallyParams struct {
+	Quorum        string `protobuf:"bytes,1,opt,name=quorum,proto3"`
+	Threshold     string `protobuf:"bytes,2,opt,name=threshold,proto3"`
+	VetoThreshold string `protobuf:"bytes,3,opt,name=veto_threshold,json=vetoThreshold,proto3"`
+}
+
+func (m *govV1TallyParams) Reset()         { *m = govV1TallyParams{} }
+func (m *govV1TallyParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1TallyParams) ProtoMessage()    {}
+
+type govV1ParamsResponse struct {
+	VotingParams  *govV1VotingParams  `protobuf:"bytes,1,opt,name=voting_params,json=votingParams,proto3"`
+	DepositParams *govV1DepositParams `protobuf:"bytes,2,opt,name=deposit_params,json=depositParams,proto3"`
+	TallyParams   *govV1TallyParams   `protobuf:"bytes,3,opt,name=tally_params,json=tallyParams,proto3"`
+}
+
+func (m *govV1ParamsResponse) Reset()         { *m = govV1ParamsResponse{} }
+func (m *govV1ParamsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*govV1ParamsResponse) ProtoMessage()    {}
+
+// govParams is what we need from both gov v1 and v1beta1 params.
+type govParams struct {
+	MinDeposit       []govV1Coin
+	MaxDepositPeriod time.Duration
+	VotingPeriod     time.Duration
+	Quorum           string
+	Threshold        string
+	VetoThreshold    string
+}
+
+// queryGovParams queries the gov params with gov v1, falling back to v1beta1 if the node doesn't have it.
+func queryGovParams(ctx context.Context, grpcConn *grpc.ClientConn) (govParams, error) {
+	var params govParams
+
+	for index, paramsType := range govParamsTypes {
+		response := &govV1ParamsResponse{}
+		err := grpcConn.Invoke(ctx, govV1ParamsMethod, &govV1ParamsRequest{ParamsType: paramsType}, response)
+		if status.Code(err) == codes.Unimplemented && index == 0 {
+			return queryGovV1beta1Params(ctx, grpcConn)
+		} else if err != nil {
+			return params, err
+		}
+
+		if response.VotingParams != nil {
+			params.VotingPeriod = getEpochDuration(response.VotingParams.VotingPeriod)
+		}
+
+		if response.DepositParams != nil {
+			params.MaxDepositPeriod = getEpochDuration(response.DepositParams.MaxDepositPeriod)
+			for _, coin := range response.DepositParams.MinDeposit {
+				if coin != nil {
+					params.MinDeposit = append(params.MinDeposit, *coin)
+				}
+			}
+		}
+
+		if response.TallyParams != nil {
+			params.Quorum = response.TallyParams.Quorum
+			params.Threshold = response.TallyParams.Threshold
+			params.VetoThreshold = response.TallyParams.VetoThreshold
+		}
+	}
+
+	return params, nil
+}
+
+func queryGovV1beta1Params(ctx context.Context, grpcConn *grpc.ClientConn) (govParams, error) {
+	var params govParams
+
+	govClient := govtypes.NewQueryClient(grpcConn)
+
+	for _, paramsType := range govParamsTypes {
+		response, err := govClient.Params(ctx, &govtypes.QueryParamsRequest{ParamsType: paramsType})
+		if err != nil {
+			return params, err
+		}
+
+		switch paramsType {
+		case "voting":
+			params.VotingPeriod = response.VotingParams.VotingPeriod
+		case "deposit":
+			params.MaxDepositPeriod = response.DepositParams.MaxDepositPeriod
+			for _, coin := range response.DepositParams.MinDeposit {
+				params.MinDeposit = append(params.MinDeposit, govV1Coin{
+					Denom:  coin.Denom,
+					Amount: coin.Amount.String(),
+				})
+			}
+		case "tallying":
+			params.Quorum = response.TallyParams.Quorum.String()
+			params.Threshold = response.TallyParams.Threshold.String()
+			params.VetoThreshold = response.TallyParams.VetoThreshold.String()
+		}
+	}
+
+	return params, nil
+}
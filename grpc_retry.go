@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Without grpc.WithBlock the dial succeeds even if the node is down, and the first
+// scrape fails instead of the exporter telling it can't connect on startup.
+const grpcDialTimeout = 10 * time.Second
+
+// retryUnaryInterceptor retries the queries that failed because the node is unreachable
+// --grpc-retry-max times, with the delay doubling each time starting from --grpc-retry-backoff.
+// The connection itself is re-established by gRPC, but it waits for its own backoff between
+// the attempts, so it's reset to redial right away. Other errors are returned as is,
+// as retrying them would just fail again.
+func retryUnaryInterceptor(
+	ctx context.Context,
+	method string,
+	request, response interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	err := invoker(ctx, method, request, response, cc, opts...)
+	backoff := GRPCRetryBackoff
+
+	for attempt := 1; attempt <= GRPCRetryMax && status.Code(err) == codes.Unavailable; attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		log.Debug().
+			Str("method", method).
+			Int("attempt", attempt).
+			Msg("Retrying gRPC query")
+
+		cc.ResetConnectBackoff()
+		err = invoker(ctx, method, request, response, cc, opts...)
+		backoff *= 2
+	}
+
+	setGRPCUp(status.Code(err) != codes.Unavailable)
+
+	// the handlers skip the modules the chain doesn't have, and count a missing vote
+	// as not voted, so these are not failures
+	if err != nil && status.Code(err) != codes.Unimplemented && !(method == govVoteMethod && isVoteNotFound(err)) {
+		markScrapeError(ctx)
+	}
+
+	return err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// getGRPCDialOptions returns the options every connection to the gRPC node is dialed with.
+func getGRPCDialOptions() ([]grpc.DialOption, error) {
+	transportOption, err := getGRPCTransportOption()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []grpc.DialOption{
+		transportOption,
+		grpc.WithBlock(),
+		grpc.WithUnaryInterceptor(retryUnaryInterceptor),
+		// the default limit is 4MB, which the validators or delegations responses exceed on large chains
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(GRPCMaxRecvMsgSize)),
+	}
+
+	// the load balancers drop the idle connections without telling anyone, so the first scrape
+	// after a pause would fail. Pinging the node keeps the connection alive, or at least lets gRPC
+	// notice it's gone and redial before the next scrape.
+	if GRPCKeepaliveTime > 0 {
+		options = append(options, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                GRPCKeepaliveTime,
+			Timeout:             GRPCKeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	return options, nil
+}
+
+// getGRPCTransportOption returns the option to connect to the gRPC node with,
+// which is plaintext unless --grpc-tls is set.
+func getGRPCTransportOption() (grpc.DialOption, error) {
+	if !GRPCTLS {
+		// grpc.WithTransportCredentials(insecure.NewCredentials()) is only available since gRPC v1.34,
+		// and the version is pinned by cosmos-sdk, so the deprecated option is used until it's bumped
+		return grpc.WithInsecure(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: GRPCTLSInsecureSkipVerify, // for self-signed setups
+	}
+
+	// if the CA is not set, the system one is used
+	if GRPCTLSCA != "" {
+		caCert, err := os.ReadFile(GRPCTLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read --grpc-tls-ca: %w", err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in --grpc-tls-ca")
+		}
+
+		tlsConfig.RootCAs = certPool
+	}
+
+	// the client certificate is only needed if the node requires mutual TLS
+	if GRPCTLSCert != "" || GRPCTLSKey != "" {
+		if GRPCTLSCert == "" || GRPCTLSKey == "" {
+			return nil, fmt.Errorf("--grpc-tls-cert and --grpc-tls-key should be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(GRPCTLSCert, GRPCTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), nil
+}
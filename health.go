@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the probes are made often, so they shouldn't hang for as long as the metrics queries
+const healthCheckTimeout = 5 * time.Second
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// checkNodeHealth makes the cheapest query there is to check the node is reachable.
+// If the node doesn't implement it, it's still reachable, so that's fine.
+func checkNodeHealth(ctx context.Context, grpcConn *grpc.ClientConn) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	serviceClient := tmservice.NewServiceClient(grpcConn)
+	_, err := serviceClient.GetNodeInfo(ctx, &tmservice.GetNodeInfoRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+
+	return err
+}
+
+func writeHealthResponse(w http.ResponseWriter, problem string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if problem == "" {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(healthResponse{Status: "ok"})
+		return
+	}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(healthResponse{Status: "error", Error: problem})
+}
+
+// HealthzHandler only checks whether the node is reachable and doesn't run any of the metrics queries,
+// so a broken query doesn't make the exporter look unhealthy.
+func HealthzHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	if err := checkNodeHealth(r.Context(), grpcConn); err != nil {
+		log.Debug().Err(err).Msg("Health check failed")
+		writeHealthResponse(w, "node is unreachable: "+err.Error())
+		return
+	}
+
+	writeHealthResponse(w, "")
+}
+
+// ReadyzHandler also checks that the chain-id and the denom were initialized,
+// as the metrics would be labeled wrong without them.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	if getConstLabels()["chain_id"] == "" {
+		writeHealthResponse(w, "chain-id is not initialized")
+		return
+	}
+
+	if Denom == "" || DenomCoefficient == 0 {
+		writeHealthResponse(w, "denom is not initialized")
+		return
+	}
+
+	HealthzHandler(w, r, grpcConn)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	connectiontypes "github.com/cosmos/cosmos-sdk/x/ibc/core/03-connection/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// getConnectionStateLabel turns STATE_TRYOPEN into tryopen, so it's consistent with the other labels.
+func getConnectionStateLabel(state connectiontypes.State) string {
+	if state == connectiontypes.UNINITIALIZED {
+		return "uninitialized"
+	}
+
+	return strings.ToLower(strings.TrimPrefix(state.String(), "STATE_"))
+}
+
+// queryIBCConnections pages through all the IBC connections of the chain.
+func queryIBCConnections(ctx context.Context, grpcConn *grpc.ClientConn) ([]*connectiontypes.IdentifiedConnection, error) {
+	connectionClient := connectiontypes.NewQueryClient(grpcConn)
+
+	var connections []*connectiontypes.IdentifiedConnection
+	var nextKey []byte
+
+	for {
+		response, err := connectionClient.Connections(ctx, &connectiontypes.QueryConnectionsRequest{
+			Pagination: &querytypes.PageRequest{
+				Key:   nextKey,
+				Limit: Limit,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		connections = append(connections, response.Connections...)
+
+		if response.Pagination == nil || len(response.Pagination.NextKey) == 0 {
+			return connections, nil
+		}
+
+		nextKey = response.Pagination.NextKey
+	}
+}
+
+func IBCHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := zerolog.Ctx(r.Context())
+
+	ctx := r.Context()
+
+	ibcConnectionsCountGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_ibc_connections_count",
+			Help:        "Amount of open IBC connections",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	ibcConnectionStateGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_ibc_connection_state",
+			Help:        "State of the IBC connection, value is always 1",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"connection_id", "client_id", "counterparty_connection_id", "counterparty_client_id", "state"},
+	)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(ibcConnectionsCountGauge)
+	registry.MustRegister(ibcConnectionStateGauge)
+
+	sublogger.Debug().Msg("Started querying IBC connections")
+	queryStart := time.Now()
+
+	connections, err := queryIBCConnections(ctx, grpcConn)
+	if status.Code(err) == codes.Unimplemented {
+		sublogger.Debug().Msg("The chain doesn't have the IBC module, not querying IBC connections")
+	} else if err != nil {
+		sublogger.Error().Err(err).Msg("Could not get IBC connections")
+	} else {
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying IBC connections")
+
+		openConnections := 0
+
+		for _, connection := range connections {
+			if connection.State == connectiontypes.OPEN {
+				openConnections++
+			}
+
+			ibcConnectionStateGauge.With(prometheus.Labels{
+				"connection_id":              connection.Id,
+				"client_id":                  connection.ClientId,
+				"counterparty_connection_id": connection.Counterparty.ConnectionId,
+				"counterparty_client_id":     connection.Counterparty.ClientId,
+				"state":                      getConnectionStateLabel(connection.State),
+			}).Set(1)
+		}
+
+		ibcConnectionsCountGauge.Set(float64(openConnections))
+	}
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/ibc").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
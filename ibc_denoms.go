@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	ibctransfertypes "github.com/cosmos/cosmos-sdk/x/ibc/applications/transfer/types"
+	"google.golang.org/grpc"
+)
+
+const ibcDenomPrefix = "ibc/"
+
+// the trace of a hash never changes, so there's no need to ever invalidate the cache
+var (
+	ibcDenomTraces      = map[string]ibctransfertypes.DenomTrace{}
+	ibcDenomTracesMutex sync.RWMutex
+)
+
+// getIBCDenomLabels returns the base denom and the trace path of the IBC denom to label the metrics with.
+// Both are empty for the native denoms. If the trace could not be resolved, the denom itself is used
+// as the base denom, so the metric is still reported.
+func getIBCDenomLabels(ctx context.Context, grpcConn *grpc.ClientConn, denom string) (string, string, error) {
+	if !strings.HasPrefix(denom, ibcDenomPrefix) {
+		return "", "", nil
+	}
+
+	trace, err := getIBCDenomTrace(ctx, grpcConn, strings.TrimPrefix(denom, ibcDenomPrefix))
+	if err != nil {
+		return denom, "", err
+	}
+
+	return trace.BaseDenom, trace.Path, nil
+}
+
+func getIBCDenomTrace(ctx context.Context, grpcConn *grpc.ClientConn, hash string) (ibctransfertypes.DenomTrace, error) {
+	ibcDenomTracesMutex.RLock()
+	trace, found := ibcDenomTraces[hash]
+	ibcDenomTracesMutex.RUnlock()
+
+	if found {
+		return trace, nil
+	}
+
+	transferClient := ibctransfertypes.NewQueryClient(grpcConn)
+	response, err := transferClient.DenomTrace(
+		ctx,
+		&ibctransfertypes.QueryDenomTraceRequest{Hash: hash},
+	)
+	if err != nil {
+		return ibctransfertypes.DenomTrace{}, err
+	}
+
+	ibcDenomTracesMutex.Lock()
+	ibcDenomTraces[hash] = *response.DenomTrace
+	ibcDenomTracesMutex.Unlock()
+
+	return *response.DenomTrace, nil
+}
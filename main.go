@@ -2,22 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	gokitlog "github.com/go-kit/log"
 
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/exporter-toolkit/web"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	tmrpc "github.com/tendermint/tendermint/rpc/client/http"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -27,10 +39,57 @@ var (
 	Denom         string
 	ListenAddress string
 	NodeAddress   string
-	TendermintRPC string
-	LogLevel      string
-	JsonOutput    bool
-	Limit         uint64
+
+	GRPCTLS                   bool
+	GRPCTLSCA                 string
+	GRPCTLSCert               string
+	GRPCTLSKey                string
+	GRPCTLSInsecureSkipVerify bool
+	GRPCRetryMax              int
+	GRPCRetryBackoff          time.Duration
+	GRPCMaxRecvMsgSize        int
+	GRPCKeepaliveTime         time.Duration
+	GRPCKeepaliveTimeout      time.Duration
+
+	TendermintRPCs  []string
+	RPCTimeout      time.Duration
+	RPCRetries      int
+	QueryTimeout    time.Duration
+	CacheTTL        time.Duration
+	ShutdownTimeout time.Duration
+	LogLevel        string
+	JsonOutput      bool
+	Limit           uint64
+
+	MaxValidatorsPerRequest int
+	MaxConcurrency          int
+	DefaultValidator        string
+	DefaultWallet           string
+	DefaultToWatched        bool
+	ProposerSampleSize      int64
+	EmaAlpha                float64
+	ValidatorsScanTimeout   time.Duration
+	ReferenceDenom          string
+	ReferenceRate           float64
+	PriceSource             string
+	CoingeckoID             string
+	PriceRefreshInterval    time.Duration
+	MetricsPrefix           string
+
+	AllowInsecureBind    bool
+	MetricsDumpFile      string
+	MetricsDumpInterval  time.Duration
+	ScanUnbondingEntries bool
+	ConcentrationTopN    int
+
+	TrustProxy            bool
+	TrustedProxiesStrings []string
+	TrustedProxies        []*net.IPNet
+
+	WatchedWallets       []string
+	WatchedValidators    []string
+	WalletDenomAllowlist []string
+	RewardsDenomFilter   []string
 
 	Prefix                    string
 	AccountPrefix             string
@@ -40,83 +99,109 @@ var (
 	ConsensusNodePrefix       string
 	ConsensusNodePubkeyPrefix string
 
-	ChainID          string
-	ConstLabels      map[string]string
-	DenomCoefficient float64
+	ChainID                     string
+	ChainIDRefreshInterval      time.Duration
+	StartupRetries              int
+	StartupRetryInterval        time.Duration
+	ValidatorSetRefreshInterval time.Duration
+	ConstLabelsStrings          []string
+	ExtraConstLabels            map[string]string
+	ConstLabels                 map[string]string
+	ConstLabelsMutex            sync.RWMutex
+	DenomCoefficient            float64
 )
 
 var log = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
 
+const envPrefix = "COSMOS_EXPORTER"
+
 var rootCmd = &cobra.Command{
 	Use:  "cosmos-exporter",
 	Long: "Scrape the data about the validators set, specific validators or wallets in the Cosmos network.",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		ConfigLoadedTime = time.Now()
+
+		// so --bech-account-prefix can be set as COSMOS_EXPORTER_BECH_ACCOUNT_PREFIX
+		viper.SetEnvPrefix(envPrefix)
+		viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+		viper.AutomaticEnv()
+
 		if ConfigPath == "" {
-			setBechPrefixes(cmd)
-			return nil
+			ConfigPath = viper.GetString("config")
 		}
 
-		viper.SetConfigFile(ConfigPath)
-		if err := viper.ReadInConfig(); err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-				log.Info().Err(err).Msg("Error reading config file")
-				return err
+		if ConfigPath != "" {
+			viper.SetConfigFile(ConfigPath)
+			if err := viper.ReadInConfig(); err != nil {
+				if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+					log.Info().Err(err).Msg("Error reading config file")
+					return err
+				}
 			}
 		}
 
 		// Credits to https://carolynvanslyck.com/blog/2020/08/sting-of-the-viper/
+		// The flags passed explicitly are kept, and viper prefers the env vars over the config file
 		cmd.Flags().VisitAll(func(f *pflag.Flag) {
 			if !f.Changed && viper.IsSet(f.Name) {
 				val := viper.Get(f.Name)
+				// lists from the config file would be formatted as "[a b]" otherwise
+				if list, ok := val.([]interface{}); ok {
+					values := make([]string, len(list))
+					for index, item := range list {
+						values[index] = fmt.Sprintf("%v", item)
+					}
+					val = strings.Join(values, ",")
+				}
+
 				if err := cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val)); err != nil {
 					log.Fatal().Err(err).Msg("Could not set flag")
 				}
 			}
 		})
 
-		setBechPrefixes(cmd)
-
-		return nil
+		return setBechPrefixes(cmd)
 	},
 	Run: Execute,
 }
 
-func setBechPrefixes(cmd *cobra.Command) {
-	if flag, err := cmd.Flags().GetString("bech-account-prefix"); flag != "" && err == nil {
-		AccountPrefix = flag
-	} else {
-		AccountPrefix = Prefix
-	}
+// setBechPrefixes takes the prefixes from the specific flags if they are set,
+// or derives them from --bech-prefix otherwise. All the problems are returned at once,
+// so the config can be fixed without restarting the exporter multiple times.
+func setBechPrefixes(cmd *cobra.Command) error {
+	var problems []string
+
+	getPrefix := func(flagName string, suffix string) string {
+		flag, err := cmd.Flags().GetString(flagName)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("could not get --%s: %s", flagName, err))
+			return ""
+		}
 
-	if flag, err := cmd.Flags().GetString("bech-account-pubkey-prefix"); flag != "" && err == nil {
-		AccountPubkeyPrefix = flag
-	} else {
-		AccountPubkeyPrefix = Prefix + "pub"
-	}
+		if flag != "" {
+			return flag
+		}
 
-	if flag, err := cmd.Flags().GetString("bech-validator-prefix"); flag != "" && err == nil {
-		ValidatorPrefix = flag
-	} else {
-		ValidatorPrefix = Prefix + "valoper"
-	}
+		if Prefix == "" {
+			problems = append(problems, fmt.Sprintf("--%s is not set and --bech-prefix is empty", flagName))
+			return ""
+		}
 
-	if flag, err := cmd.Flags().GetString("bech-validator-pubkey-prefix"); flag != "" && err == nil {
-		ValidatorPubkeyPrefix = flag
-	} else {
-		ValidatorPubkeyPrefix = Prefix + "valoperpub"
+		return Prefix + suffix
 	}
 
-	if flag, err := cmd.Flags().GetString("bech-consensus-node-prefix"); flag != "" && err == nil {
-		ConsensusNodePrefix = flag
-	} else {
-		ConsensusNodePrefix = Prefix + "valcons"
-	}
+	AccountPrefix = getPrefix("bech-account-prefix", "")
+	AccountPubkeyPrefix = getPrefix("bech-account-pubkey-prefix", "pub")
+	ValidatorPrefix = getPrefix("bech-validator-prefix", "valoper")
+	ValidatorPubkeyPrefix = getPrefix("bech-validator-pubkey-prefix", "valoperpub")
+	ConsensusNodePrefix = getPrefix("bech-consensus-node-prefix", "valcons")
+	ConsensusNodePubkeyPrefix = getPrefix("bech-consensus-node-pubkey-prefix", "valconspub")
 
-	if flag, err := cmd.Flags().GetString("bech-consensus-node-pubkey-prefix"); flag != "" && err == nil {
-		ConsensusNodePubkeyPrefix = flag
-	} else {
-		ConsensusNodePubkeyPrefix = Prefix + "valconspub"
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid bech32 prefixes: %s", strings.Join(problems, "; "))
 	}
+
+	return nil
 }
 
 func Execute(cmd *cobra.Command, args []string) {
@@ -131,7 +216,127 @@ func Execute(cmd *cobra.Command, args []string) {
 
 	zerolog.SetGlobalLevel(logLevel)
 
+	for _, cidr := range TrustedProxiesStrings {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Fatal().Err(err).Str("cidr", cidr).Msg("Could not parse trusted proxy CIDR")
+		}
+
+		TrustedProxies = append(TrustedProxies, network)
+	}
+
+	ExtraConstLabels = map[string]string{}
+	for _, label := range ConstLabelsStrings {
+		name, value, err := parseConstLabel(label)
+		if err != nil {
+			log.Fatal().Err(err).Str("--const-label", label).Msg("Could not parse const label")
+		}
+
+		if _, ok := ExtraConstLabels[name]; ok {
+			log.Fatal().Str("--const-label", label).Msg("Const label is set more than once")
+		}
+
+		ExtraConstLabels[name] = value
+	}
+
+	listenHost, listenPort, err := net.SplitHostPort(ListenAddress)
+	if err != nil {
+		log.Fatal().Err(err).Str("--listen-address", ListenAddress).Msg("Could not parse listen address")
+	}
+
+	if _, err := strconv.ParseUint(listenPort, 10, 16); err != nil {
+		log.Fatal().Err(err).Str("--listen-address", ListenAddress).Msg("Invalid listen address port")
+	}
+
+	// without --web-config there's neither TLS nor auth, so anyone who can reach the host can scrape the exporter
+	if isAllInterfacesHost(listenHost) && WebConfigPath == "" && !AllowInsecureBind {
+		log.Warn().
+			Str("--listen-address", ListenAddress).
+			Msg("Listening on all interfaces without TLS or auth. Consider listening on 127.0.0.1 or setting --web-config. Pass --allow-insecure-bind to suppress this warning")
+	}
+
+	if MetricsDumpFile != "" && MetricsDumpInterval <= 0 {
+		log.Fatal().Dur("--metrics-dump-interval", MetricsDumpInterval).Msg("--metrics-dump-interval should be positive if --metrics-dump-file is set")
+	}
+
+	if ShutdownTimeout <= 0 {
+		log.Fatal().Dur("--shutdown-timeout", ShutdownTimeout).Msg("--shutdown-timeout should be positive")
+	}
+
+	if RPCRetries < 0 {
+		log.Fatal().Int("--rpc-retries", RPCRetries).Msg("--rpc-retries should not be negative")
+	}
+
+	if StartupRetries < 0 {
+		log.Fatal().Int("--startup-retries", StartupRetries).Msg("--startup-retries should not be negative")
+	}
+
+	if StartupRetryInterval < 0 {
+		log.Fatal().Dur("--startup-retry-interval", StartupRetryInterval).Msg("--startup-retry-interval should not be negative")
+	}
+
+	if MaxConcurrency <= 0 {
+		log.Fatal().Int("--max-concurrency", MaxConcurrency).Msg("--max-concurrency should be positive")
+	}
+
+	if GRPCRetryMax < 0 {
+		log.Fatal().Int("--grpc-retry-max", GRPCRetryMax).Msg("--grpc-retry-max should not be negative")
+	}
+
+	if GRPCKeepaliveTime < 0 {
+		log.Fatal().Dur("--grpc-keepalive-time", GRPCKeepaliveTime).Msg("--grpc-keepalive-time should not be negative")
+	}
+
+	if GRPCKeepaliveTime > 0 && GRPCKeepaliveTimeout <= 0 {
+		log.Fatal().Dur("--grpc-keepalive-timeout", GRPCKeepaliveTimeout).Msg("--grpc-keepalive-timeout should be positive if --grpc-keepalive-time is set")
+	}
+
+	if GRPCMaxRecvMsgSize <= 0 {
+		log.Fatal().Int("--grpc-max-recv-msg-size", GRPCMaxRecvMsgSize).Msg("--grpc-max-recv-msg-size should be positive")
+	}
+
+	if GRPCRetryBackoff < 0 {
+		log.Fatal().Dur("--grpc-retry-backoff", GRPCRetryBackoff).Msg("--grpc-retry-backoff should not be negative")
+	}
+
+	if DenomCoefficient < 0 {
+		log.Fatal().Float64("--denom-coefficient", DenomCoefficient).Msg("--denom-coefficient should be positive")
+	}
+
+	if ReferenceDenom != "" && ReferenceRate <= 0 {
+		log.Fatal().Float64("--reference-rate", ReferenceRate).Msg("--reference-rate should be positive if --reference-denom is set")
+	}
+
+	if PriceSource != "" && PriceSource != priceSourceCoingecko {
+		log.Fatal().Str("--price-source", PriceSource).Msg("--price-source should be either empty or coingecko")
+	}
+
+	if PriceSource == priceSourceCoingecko && CoingeckoID == "" {
+		log.Fatal().Msg("--coingecko-id should be set if --price-source is coingecko")
+	}
+
+	if PriceSource != "" && PriceRefreshInterval <= 0 {
+		log.Fatal().Dur("--price-refresh-interval", PriceRefreshInterval).Msg("--price-refresh-interval should be positive if --price-source is set")
+	}
+
+	// the rest of the name is valid, so if the prefix is a valid name, the whole one is too
+	if !model.IsValidMetricName(model.LabelValue(MetricsPrefix)) {
+		log.Fatal().Str("--metrics-prefix", MetricsPrefix).Msg("--metrics-prefix should be a valid Prometheus metric name")
+	}
+
+	if ProposerSampleSize < 0 || ProposerSampleSize > maxProposerSampleSize {
+		log.Fatal().
+			Int64("--proposer-sample-size", ProposerSampleSize).
+			Msgf("--proposer-sample-size should be between 0 and %d", maxProposerSampleSize)
+	}
+
+	if EmaAlpha < 0 || EmaAlpha > 1 {
+		log.Fatal().Float64("--ema-alpha", EmaAlpha).Msg("--ema-alpha should be between 0 and 1")
+	}
+
 	log.Info().
+		Str("version", version).
+		Str("commit", commit).
 		Str("--bech-account-prefix", AccountPrefix).
 		Str("--bech-account-pubkey-prefix", AccountPubkeyPrefix).
 		Str("--bech-validator-prefix", ValidatorPrefix).
@@ -140,9 +345,55 @@ func Execute(cmd *cobra.Command, args []string) {
 		Str("--bech-consensus-node-pubkey-prefix", ConsensusNodePubkeyPrefix).
 		Str("--denom", Denom).
 		Str("--listen-address", ListenAddress).
+		Bool("--allow-insecure-bind", AllowInsecureBind).
 		Str("--node", NodeAddress).
+		Bool("--grpc-tls", GRPCTLS).
+		Str("--grpc-tls-ca", GRPCTLSCA).
+		Str("--grpc-tls-cert", GRPCTLSCert).
+		Str("--grpc-tls-key", GRPCTLSKey).
+		Bool("--grpc-tls-insecure-skip-verify", GRPCTLSInsecureSkipVerify).
+		Int("--grpc-retry-max", GRPCRetryMax).
+		Dur("--grpc-retry-backoff", GRPCRetryBackoff).
+		Int("--grpc-max-recv-msg-size", GRPCMaxRecvMsgSize).
+		Dur("--grpc-keepalive-time", GRPCKeepaliveTime).
+		Dur("--grpc-keepalive-timeout", GRPCKeepaliveTimeout).
+		Strs("--tendermint-rpc", TendermintRPCs).
+		Strs("--const-label", ConstLabelsStrings).
+		Dur("--rpc-timeout", RPCTimeout).
+		Int("--rpc-retries", RPCRetries).
+		Dur("--query-timeout", QueryTimeout).
+		Dur("--shutdown-timeout", ShutdownTimeout).
+		Dur("--cache-ttl", CacheTTL).
 		Str("--log-level", LogLevel).
 		Str("--web-config", WebConfigPath).
+		Dur("--validators-scan-timeout", ValidatorsScanTimeout).
+		Int("--max-concurrency", MaxConcurrency).
+		Dur("--validator-set-refresh-interval", ValidatorSetRefreshInterval).
+		Dur("--chain-id-refresh-interval", ChainIDRefreshInterval).
+		Int("--startup-retries", StartupRetries).
+		Dur("--startup-retry-interval", StartupRetryInterval).
+		Str("--reference-denom", ReferenceDenom).
+		Float64("--reference-rate", ReferenceRate).
+		Str("--metrics-prefix", MetricsPrefix).
+		Str("--price-source", PriceSource).
+		Str("--coingecko-id", CoingeckoID).
+		Dur("--price-refresh-interval", PriceRefreshInterval).
+		Bool("--trust-proxy", TrustProxy).
+		Strs("--trusted-proxies", TrustedProxiesStrings).
+		Str("--default-validator", DefaultValidator).
+		Str("--default-wallet", DefaultWallet).
+		Bool("--default-to-watched", DefaultToWatched).
+		Int64("--proposer-sample-size", ProposerSampleSize).
+		Float64("--ema-alpha", EmaAlpha).
+		Strs("--watch-wallet", WatchedWallets).
+		Strs("--watch-validator", WatchedValidators).
+		Strs("--wallet-denom-allowlist", WalletDenomAllowlist).
+		Strs("--rewards-denom-filter", RewardsDenomFilter).
+		Bool("--scan-unbonding-entries", ScanUnbondingEntries).
+		Int("--concentration-top-n", ConcentrationTopN).
+		Str("--metrics-dump-file", MetricsDumpFile).
+		Dur("--metrics-dump-interval", MetricsDumpInterval).
+		Str("config-precedence", "flags > "+envPrefix+"_* env vars > --config file > defaults").
 		Msg("Started with following parameters")
 
 	config := sdk.GetConfig()
@@ -151,57 +402,433 @@ func Execute(cmd *cobra.Command, args []string) {
 	config.SetBech32PrefixForConsensusNode(ConsensusNodePrefix, ConsensusNodePubkeyPrefix)
 	config.Seal()
 
-	grpcConn, err := grpc.Dial(
-		NodeAddress,
-		grpc.WithInsecure(),
-	)
+	grpcDialOptions, err := getGRPCDialOptions()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not set up gRPC TLS")
+	}
+
+	dialCtx, cancelDial := context.WithTimeout(context.Background(), grpcDialTimeout)
+	grpcConn, err := grpc.DialContext(dialCtx, NodeAddress, grpcDialOptions...)
+	cancelDial()
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not connect to gRPC node")
 	}
 
+	if err := initTendermintEndpoints(); err != nil {
+		log.Fatal().Err(err).Msg("Could not set up Tendermint RPC")
+	}
+
 	setChainID()
+	initExporterMetrics()
+	checkChainID(grpcConn)
+	checkBechPrefix(grpcConn)
 	setDenom(grpcConn)
+	setDenomInfos(grpcConn)
+	setEpochMintAvailable(grpcConn)
 
+	if ChainIDRefreshInterval != 0 {
+		go refreshChainID()
+	}
+
+	if ValidatorSetRefreshInterval != 0 {
+		go refreshValidatorSetSnapshot(grpcConn)
+	}
+
+	if PriceSource != "" {
+		go refreshPricePeriodically()
+	}
+
+	// the name is used as the handler label of the scrape metrics, so it's fixed
+	// instead of being taken from the request path
 	makeHandler := func(
+		name string,
 		handler func(http.ResponseWriter, *http.Request, *grpc.ClientConn),
 		grpcConn *grpc.ClientConn,
+		timeout time.Duration,
 	) func(http.ResponseWriter, *http.Request) {
 		return func(w http.ResponseWriter, r *http.Request) {
-			handler(w, r, grpcConn)
+			requestStart := time.Now()
+			requestID := uuid.New().String()
+			w.Header().Set("X-Request-ID", requestID)
+
+			sublogger := log.With().
+				Str("request-id", requestID).
+				Str("remote-address", getRemoteAddress(r)).
+				Logger()
+
+			// the queries are made with the request context, so they're aborted if the client is gone
+			// or the node doesn't respond in time, instead of the scrape hanging forever
+			ctx := sublogger.WithContext(r.Context())
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			ctx, scrapeErrors := withScrapeErrors(ctx)
+
+			resolveChainID(grpcConn)
+
+			serveCached(w, r.WithContext(ctx), func(w http.ResponseWriter, r *http.Request) {
+				handler(w, r, grpcConn)
+			})
+
+			observeScrape(name, requestStart, scrapeErrors)
 		}
 	}
+	// scanning the whole validators set might take much longer than other queries
+	validatorsTimeout := QueryTimeout
+	if ValidatorsScanTimeout > 0 {
+		validatorsTimeout = ValidatorsScanTimeout
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/metrics/wallet", makeHandler(WalletHandler, grpcConn))
-	mux.HandleFunc("/metrics/validator", makeHandler(ValidatorHandler, grpcConn))
-	mux.HandleFunc("/metrics/validators", makeHandler(ValidatorsHandler, grpcConn))
-	mux.HandleFunc("/metrics/params", makeHandler(ParamsHandler, grpcConn))
-	mux.HandleFunc("/metrics/general", makeHandler(GeneralHandler, grpcConn))
+	mux.HandleFunc("/metrics/wallet", makeHandler("wallet", WalletHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/validator", makeHandler("validator", ValidatorHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/validators", makeHandler("validators", ValidatorsHandler, grpcConn, validatorsTimeout))
+	mux.HandleFunc("/metrics/params", makeHandler("params", ParamsHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/general", makeHandler("general", GeneralHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/watched-wallets", makeHandler("watched-wallets", WatchedWalletsHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/proposals", makeHandler("proposals", ProposalsHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/rewards-snapshot", makeHandler("rewards-snapshot", RewardsSnapshotHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/wasm", makeHandler("wasm", WasmHandler, grpcConn, QueryTimeout))
+	mux.HandleFunc("/metrics/ibc", makeHandler("ibc", IBCHandler, grpcConn, QueryTimeout))
+
+	// not using makeHandler, as the probes shouldn't be cached or counted as scrapes
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		HealthzHandler(w, r, grpcConn)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ReadyzHandler(w, r, grpcConn)
+	})
+
+	mux.HandleFunc("/metrics", makeHandler("aggregate", AggregateHandler, grpcConn, QueryTimeout))
+
+	if MetricsDumpFile != "" {
+		go dumpMetricsPeriodically(mux)
+	}
 
 	log.Info().Str("address", ListenAddress).Msg("Listening")
 	server := &http.Server{Addr: ListenAddress, Handler: mux}
-	if err := web.ListenAndServe(server, WebConfigPath, gokitlog.NewLogfmtLogger(log)); err != nil {
+
+	shutdownDone := make(chan struct{})
+	go shutdownOnSignal(server, grpcConn, shutdownDone)
+
+	if err := web.ListenAndServe(server, WebConfigPath, gokitlog.NewLogfmtLogger(log)); !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal().Err(err).Msg("Could not start application")
 	}
+
+	<-shutdownDone
+	log.Info().Msg("Exporter stopped")
 }
 
+// setChainID queries the chain-id on startup. The node might be starting at the same time
+// as the exporter, so it's retried --startup-retries times, and if it's still not available,
+// the exporter starts anyway and the chain-id is queried again on the next scrapes.
 func setChainID() {
-	client, err := tmrpc.New(TendermintRPC, "/websocket")
+	chainID, err := getChainID()
+	interval := StartupRetryInterval
+
+	for attempt := 1; attempt <= StartupRetries && err != nil; attempt++ {
+		log.Warn().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("retry-in", interval).
+			Msg("Could not query Tendermint status, retrying")
+
+		time.Sleep(interval)
+		interval *= 2
+		chainID, err = getChainID()
+	}
+
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not create Tendermint client")
+		log.Warn().
+			Err(err).
+			Msg("Could not query Tendermint status, starting without chain-id. It will be queried again on the next scrape")
+	} else {
+		log.Info().Str("network", chainID).Msg("Got network status from Tendermint")
+	}
+
+	ChainID = chainID
+	ConstLabels = buildConstLabels(ChainID)
+}
+
+// set to 1 while the chain-id is being queried in the background
+var resolvingChainID int32
+
+// resolveChainID is called on each scrape, and if the chain-id could not be queried on startup,
+// queries it in the background. The scrape itself doesn't wait for it, as if the Tendermint RPC
+// is still down, every scrape would take --rpc-timeout longer.
+func resolveChainID(grpcConn *grpc.ClientConn) {
+	if getConstLabels()["chain_id"] != "" || !atomic.CompareAndSwapInt32(&resolvingChainID, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&resolvingChainID, 0)
+
+		chainID, err := getChainID()
+		if err != nil {
+			log.Warn().Err(err).Msg("Could not query Tendermint status, chain-id is still unknown")
+			return
+		}
+
+		log.Info().Str("network", chainID).Msg("Got network status from Tendermint")
+		updateChainID(chainID)
+		checkChainID(grpcConn)
+	}()
+}
+
+// updateChainID makes the metrics labeled with the new chain-id.
+func updateChainID(chainID string) {
+	ConstLabelsMutex.Lock()
+	ChainID = chainID
+	ConstLabels = buildConstLabels(ChainID)
+	ConstLabelsMutex.Unlock()
+}
+
+func getChainID() (string, error) {
+	client, err := newTendermintClient()
+	if err != nil {
+		return "", err
 	}
 
 	status, err := client.Status(context.Background())
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not query Tendermint status")
+		return "", err
+	}
+
+	return status.NodeInfo.Network, nil
+}
+
+// checkChainID compares the chain-id reported by Tendermint RPC with the one reported
+// by the gRPC node, as if --node and --tendermint-rpc point to different chains,
+// the metrics would be labeled with a wrong chain-id.
+func checkChainID(grpcConn *grpc.ClientConn) {
+	// nothing to compare with, it will be checked once the chain-id is known
+	chainID := getConstLabels()["chain_id"]
+	if chainID == "" {
+		return
+	}
+
+	ctx, cancel := getStartupQueryContext()
+	defer cancel()
+
+	serviceClient := tmservice.NewServiceClient(grpcConn)
+	response, err := serviceClient.GetNodeInfo(
+		ctx,
+		&tmservice.GetNodeInfoRequest{},
+	)
+	if status.Code(err) == codes.Unimplemented {
+		log.Debug().Msg("gRPC node doesn't report its chain-id, not checking it")
+		return
+	} else if err != nil {
+		log.Warn().Err(err).Msg("Could not get chain-id from gRPC node, not checking it")
+		return
+	}
+
+	grpcChainID := response.GetDefaultNodeInfo().GetNetwork()
+	if grpcChainID != chainID {
+		log.Error().
+			Str("tendermint-chain-id", chainID).
+			Str("grpc-chain-id", grpcChainID).
+			Msg("--node and --tendermint-rpc point to different chains")
+		setChainIDMismatch(true)
+		return
+	}
+
+	setChainIDMismatch(false)
+}
+
+// getStartupQueryContext returns the context for the queries made on startup, limited with
+// --query-timeout, so the exporter doesn't hang on startup if the node doesn't respond.
+func getStartupQueryContext() (context.Context, context.CancelFunc) {
+	if QueryTimeout > 0 {
+		return context.WithTimeout(context.Background(), QueryTimeout)
+	}
+
+	return context.WithCancel(context.Background())
+}
+
+// checkBechPrefix takes a validator from the live set and verifies its address decodes
+// with the configured prefix, as a wrong --bech-prefix makes the queries fail confusingly.
+func checkBechPrefix(grpcConn *grpc.ClientConn) {
+	ctx, cancel := getStartupQueryContext()
+	defer cancel()
+
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	response, err := stakingClient.Validators(
+		ctx,
+		&stakingtypes.QueryValidatorsRequest{
+			Pagination: &querytypes.PageRequest{
+				Limit: 1,
+			},
+		},
+	)
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not get validators, not checking bech prefix")
+		return
+	}
+
+	if len(response.Validators) == 0 {
+		log.Warn().Msg("Validators set is empty, not checking bech prefix")
+		return
+	}
+
+	operatorAddress := response.Validators[0].OperatorAddress
+	if _, err := sdk.ValAddressFromBech32(operatorAddress); err != nil {
+		chainPrefix := strings.SplitN(operatorAddress, "1", 2)[0]
+		log.Warn().
+			Err(err).
+			Str("address", operatorAddress).
+			Str("chain-prefix", chainPrefix).
+			Str("configured-prefix", ValidatorPrefix).
+			Msg("Validator address doesn't match the configured prefix, check --bech-prefix")
+		setBechPrefixOK(false)
+		return
 	}
 
-	log.Info().Str("network", status.NodeInfo.Network).Msg("Got network status from Tendermint")
-	ChainID = status.NodeInfo.Network
-	ConstLabels = map[string]string{
-		"chain_id": ChainID,
+	setBechPrefixOK(true)
+}
+
+// refreshChainID re-queries the chain-id every --chain-id-refresh-interval, so the metrics
+// get the new chain_id label if the chain was hard-forked without restarting the exporter.
+func refreshChainID() {
+	ticker := time.NewTicker(ChainIDRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		chainID, err := getChainID()
+		if err != nil {
+			log.Error().Err(err).Msg("Could not refresh chain-id")
+			continue
+		}
+
+		oldChainID := getConstLabels()["chain_id"]
+		if chainID == oldChainID {
+			continue
+		}
+
+		log.Warn().
+			Str("old", oldChainID).
+			Str("new", chainID).
+			Msg("Chain-id has changed")
+
+		updateChainID(chainID)
 	}
 }
 
+// getConstLabels should be used instead of accessing ConstLabels directly,
+// as they can be changed in the background by refreshChainID.
+// The map itself is never modified, only replaced, so it's safe to use it after unlocking.
+func getConstLabels() map[string]string {
+	ConstLabelsMutex.RLock()
+	defer ConstLabelsMutex.RUnlock()
+
+	return ConstLabels
+}
+
+// exporterLabelNames are the labels the exporter sets on its own metrics. A const label with
+// one of these names would clash with them, so it's not allowed.
+var exporterLabelNames = map[string]bool{
+	"address":                    true,
+	"app_protocol_version":       true,
+	"base_denom":                 true,
+	"block_protocol_version":     true,
+	"chain_id":                   true,
+	"client_id":                  true,
+	"commit":                     true,
+	"completion_time":            true,
+	"connection_id":              true,
+	"contract":                   true,
+	"counterparty_client_id":     true,
+	"counterparty_connection_id": true,
+	"date":                       true,
+	"delegated_by":               true,
+	"delegated_to":               true,
+	"denom":                      true,
+	"details":                    true,
+	"dst_validator":              true,
+	"endpoint":                   true,
+	"epoch_identifier":           true,
+	"goversion":                  true,
+	"handler":                    true,
+	"height":                     true,
+	"identity":                   true,
+	"moniker":                    true,
+	"option":                     true,
+	"p2p_protocol_version":       true,
+	"proposal_id":                true,
+	"query":                      true,
+	"redelegated_by":             true,
+	"redelegated_from":           true,
+	"redelegated_to":             true,
+	"security_contact":           true,
+	"src_validator":              true,
+	"state":                      true,
+	"status":                     true,
+	"title":                      true,
+	"trace_path":                 true,
+	"type":                       true,
+	"unbonded_by":                true,
+	"unbonded_from":              true,
+	"valcons":                    true,
+	"validator":                  true,
+	"validator_address":          true,
+	"version":                    true,
+	"wallet":                     true,
+	"website":                    true,
+	"withdraw_address":           true,
+}
+
+// parseConstLabel parses a --const-label value in the key=value format.
+func parseConstLabel(label string) (string, string, error) {
+	parts := strings.SplitN(label, "=", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("expected key=value")
+	}
+
+	name, value := parts[0], parts[1]
+
+	// names starting with __ are reserved for Prometheus internal use
+	if !model.LabelName(name).IsValid() || strings.HasPrefix(name, "__") {
+		return "", "", fmt.Errorf("invalid label name %q", name)
+	}
+
+	if exporterLabelNames[name] {
+		return "", "", fmt.Errorf("%s label is set by the exporter itself", name)
+	}
+
+	if value == "" || !model.LabelValue(value).IsValid() {
+		return "", "", fmt.Errorf("invalid label value %q", value)
+	}
+
+	return name, value, nil
+}
+
+// buildConstLabels returns the labels added to every metric, which are the chain-id
+// and the ones set with --const-label.
+func buildConstLabels(chainID string) map[string]string {
+	labels := map[string]string{
+		"chain_id": chainID,
+	}
+
+	for name, value := range ExtraConstLabels {
+		labels[name] = value
+	}
+
+	return labels
+}
+
+func isAllInterfacesHost(host string) bool {
+	if host == "" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
 func setDenom(grpcConn *grpc.ClientConn) {
 	// if --denom and --denom-coefficient are both provided, use them
 	// instead of fetching them via gRPC. Can be useful for networks like osmosis.
@@ -213,9 +840,12 @@ func setDenom(grpcConn *grpc.ClientConn) {
 		return
 	}
 
+	ctx, cancel := getStartupQueryContext()
+	defer cancel()
+
 	bankClient := banktypes.NewQueryClient(grpcConn)
 	denoms, err := bankClient.DenomsMetadata(
-		context.Background(),
+		ctx,
 		&banktypes.QueryDenomsMetadataRequest{},
 	)
 	if err != nil {
@@ -226,9 +856,14 @@ func setDenom(grpcConn *grpc.ClientConn) {
 		log.Fatal().Msg("No denom infos. Try running the binary with --denom and --denom-coefficient to set them manually.")
 	}
 
-	metadata := denoms.Metadatas[0] // always using the first one
-	if Denom == "" {                // using display currency
+	metadata := getDenomMetadata(denoms.Metadatas, Denom)
+	if Denom == "" { // using display currency
 		Denom = metadata.Display
+		log.Info().
+			Str("denom", Denom).
+			Str("base", metadata.Base).
+			Int("metadatas", len(denoms.Metadatas)).
+			Msg("--denom is not set, using the display denom of the first denom metadata. Set --denom if it's not the staking one")
 	}
 
 	for _, unit := range metadata.DenomUnits {
@@ -237,7 +872,7 @@ func setDenom(grpcConn *grpc.ClientConn) {
 			Uint32("exponent", unit.Exponent).
 			Msg("Denom info")
 		if unit.Denom == Denom {
-			DenomCoefficient = math.Pow10(int(unit.Exponent))
+			DenomCoefficient = getDenomCoefficient(metadata, unit.Exponent)
 			log.Info().
 				Str("denom", Denom).
 				Float64("coefficient", DenomCoefficient).
@@ -246,20 +881,71 @@ func setDenom(grpcConn *grpc.ClientConn) {
 		}
 	}
 
-	log.Fatal().Msg("Could not find the denom info")
+	log.Fatal().
+		Str("denom", Denom).
+		Msg("Could not find the denom info in any of the denom metadatas. Try running the binary with --denom-coefficient to set it manually.")
 }
 
 func main() {
+	// cobra adds the --version flag if the version is set
+	rootCmd.Version = version
+	rootCmd.SetVersionTemplate(getVersionString() + "\n")
+
 	rootCmd.PersistentFlags().StringVar(&ConfigPath, "config", "", "Config file path")
 	rootCmd.PersistentFlags().StringVar(&WebConfigPath, "web-config", "", "TLS config file path")
 	rootCmd.PersistentFlags().StringVar(&Denom, "denom", "", "Cosmos coin denom")
-	rootCmd.PersistentFlags().Float64Var(&DenomCoefficient, "denom-coefficient", 0, "Denom coefficient")
+	rootCmd.PersistentFlags().Float64Var(&DenomCoefficient, "denom-coefficient", 0, "Denom coefficient, can be fractional")
 	rootCmd.PersistentFlags().StringVar(&ListenAddress, "listen-address", ":9300", "The address this exporter would listen on")
+	rootCmd.PersistentFlags().BoolVar(&AllowInsecureBind, "allow-insecure-bind", false, "Do not warn about listening on all interfaces without TLS or auth")
 	rootCmd.PersistentFlags().StringVar(&NodeAddress, "node", "localhost:9090", "RPC node address")
+	rootCmd.PersistentFlags().BoolVar(&GRPCTLS, "grpc-tls", false, "Connect to the gRPC node over TLS")
+	rootCmd.PersistentFlags().StringVar(&GRPCTLSCA, "grpc-tls-ca", "", "CA bundle to verify the gRPC node certificate with, the system one if empty")
+	rootCmd.PersistentFlags().StringVar(&GRPCTLSCert, "grpc-tls-cert", "", "Client certificate to connect to the gRPC node with")
+	rootCmd.PersistentFlags().StringVar(&GRPCTLSKey, "grpc-tls-key", "", "Client certificate key to connect to the gRPC node with")
+	rootCmd.PersistentFlags().BoolVar(&GRPCTLSInsecureSkipVerify, "grpc-tls-insecure-skip-verify", false, "Do not verify the gRPC node certificate")
+	rootCmd.PersistentFlags().IntVar(&GRPCRetryMax, "grpc-retry-max", 3, "How many times to retry a gRPC query if the node is unreachable")
+	rootCmd.PersistentFlags().DurationVar(&GRPCRetryBackoff, "grpc-retry-backoff", 500*time.Millisecond, "Delay before the first gRPC query retry, doubled on each next one")
+	rootCmd.PersistentFlags().IntVar(&GRPCMaxRecvMsgSize, "grpc-max-recv-msg-size", 4*1024*1024, "Max size of a gRPC response in bytes")
+	rootCmd.PersistentFlags().DurationVar(&GRPCKeepaliveTime, "grpc-keepalive-time", 30*time.Second, "How often to ping the gRPC node to keep the connection alive, 0 disables pinging")
+	rootCmd.PersistentFlags().DurationVar(&GRPCKeepaliveTimeout, "grpc-keepalive-timeout", 10*time.Second, "How long to wait for the gRPC node to respond to a ping before closing the connection")
 	rootCmd.PersistentFlags().StringVar(&LogLevel, "log-level", "info", "Logging level")
 	rootCmd.PersistentFlags().Uint64Var(&Limit, "limit", 1000, "Pagination limit for gRPC requests")
-	rootCmd.PersistentFlags().StringVar(&TendermintRPC, "tendermint-rpc", "http://localhost:26657", "Tendermint RPC address")
+	rootCmd.PersistentFlags().StringSliceVar(&TendermintRPCs, "tendermint-rpc", []string{"http://localhost:26657"}, "Tendermint RPC addresses, tried in order if one of them is down")
+	rootCmd.PersistentFlags().DurationVar(&RPCTimeout, "rpc-timeout", 10*time.Second, "Timeout for Tendermint RPC requests, including retries, 0 means no timeout")
+	rootCmd.PersistentFlags().IntVar(&RPCRetries, "rpc-retries", 2, "How many times to retry a failed Tendermint RPC request")
+	rootCmd.PersistentFlags().DurationVar(&QueryTimeout, "query-timeout", 10*time.Second, "Timeout for the node queries of a single request, 0 means no timeout")
+	rootCmd.PersistentFlags().DurationVar(&ShutdownTimeout, "shutdown-timeout", 15*time.Second, "How long to wait for the in-flight scrapes to finish on SIGINT or SIGTERM")
+	rootCmd.PersistentFlags().DurationVar(&CacheTTL, "cache-ttl", 0, "How long to serve the same response without querying the node again, 0 disables caching")
 	rootCmd.PersistentFlags().BoolVar(&JsonOutput, "json", false, "Output logs as JSON")
+	rootCmd.PersistentFlags().IntVar(&MaxValidatorsPerRequest, "max-validators-per-request", 10, "Max amount of validators that can be queried in one /metrics/validator request")
+	rootCmd.PersistentFlags().IntVar(&MaxConcurrency, "max-concurrency", 5, "Max amount of validators, wallets or blocks pages queried at once in one /metrics/validator or /metrics/wallet request")
+	rootCmd.PersistentFlags().DurationVar(&ChainIDRefreshInterval, "chain-id-refresh-interval", 0, "How often to re-query the chain-id, 0 means only query it on startup")
+	rootCmd.PersistentFlags().IntVar(&StartupRetries, "startup-retries", 5, "How many times to retry querying the chain-id on startup before starting without it")
+	rootCmd.PersistentFlags().DurationVar(&StartupRetryInterval, "startup-retry-interval", 2*time.Second, "Delay before the first chain-id query retry on startup, doubled on each next one")
+	rootCmd.PersistentFlags().DurationVar(&ValidatorsScanTimeout, "validators-scan-timeout", 0, "Timeout for /metrics/validators queries, --query-timeout if 0")
+	rootCmd.PersistentFlags().DurationVar(&ValidatorSetRefreshInterval, "validator-set-refresh-interval", 0, "How often to refresh the validators set snapshot for /metrics/validators, 0 means querying it on each request")
+	rootCmd.PersistentFlags().StringVar(&ReferenceDenom, "reference-denom", "", "Denom to additionally report validator tokens in")
+	rootCmd.PersistentFlags().Float64Var(&ReferenceRate, "reference-rate", 0, "How much of --reference-denom is one --denom worth")
+	rootCmd.PersistentFlags().StringVar(&MetricsPrefix, "metrics-prefix", defaultMetricsPrefix, "Prefix of all the metrics names, so the exporters for different chains don't collide")
+	rootCmd.PersistentFlags().StringVar(&PriceSource, "price-source", "", "Where to get the --denom price from to report the values in USD, only coingecko is supported, disabled if empty")
+	rootCmd.PersistentFlags().StringVar(&CoingeckoID, "coingecko-id", "", "Coingecko ID of --denom, like cosmos")
+	rootCmd.PersistentFlags().DurationVar(&PriceRefreshInterval, "price-refresh-interval", 5*time.Minute, "How often to refresh the --denom price")
+	rootCmd.PersistentFlags().BoolVar(&TrustProxy, "trust-proxy", false, "Take client address from X-Forwarded-For header")
+	rootCmd.PersistentFlags().StringSliceVar(&ConstLabelsStrings, "const-label", []string{}, "Labels in the key=value format to add to every metric, like network=mainnet")
+	rootCmd.PersistentFlags().StringSliceVar(&TrustedProxiesStrings, "trusted-proxies", []string{}, "CIDRs of proxies to skip in X-Forwarded-For header when --trust-proxy is set")
+	rootCmd.PersistentFlags().StringVar(&DefaultValidator, "default-validator", "", "Validator address to use in /metrics/validator if none is passed")
+	rootCmd.PersistentFlags().StringVar(&DefaultWallet, "default-wallet", "", "Wallet address to use in /metrics/wallet if none is passed")
+	rootCmd.PersistentFlags().BoolVar(&DefaultToWatched, "default-to-watched", false, "Use --watch-wallet and --watch-validator in /metrics/wallet and /metrics/validator if no address is passed")
+	rootCmd.PersistentFlags().Int64Var(&ProposerSampleSize, "proposer-sample-size", 0, "How many last blocks to check the proposers of in /metrics/validator, 0 disables it")
+	rootCmd.PersistentFlags().Float64Var(&EmaAlpha, "ema-alpha", 0, "Smoothing factor for rate metrics, from 0 to 1, 0 disables smoothing")
+	rootCmd.PersistentFlags().StringSliceVar(&WatchedWallets, "watch-wallet", []string{}, "Wallet addresses to aggregate balances for")
+	rootCmd.PersistentFlags().StringSliceVar(&WatchedValidators, "watch-validator", []string{}, "Validator addresses to report the watched wallets delegations to")
+	rootCmd.PersistentFlags().StringSliceVar(&WalletDenomAllowlist, "wallet-denom-allowlist", []string{}, "Denoms to return wallet balances for, all if empty")
+	rootCmd.PersistentFlags().StringSliceVar(&RewardsDenomFilter, "rewards-denom-filter", []string{}, "Denoms to return rewards and commission for, all if empty")
+	rootCmd.PersistentFlags().BoolVar(&ScanUnbondingEntries, "scan-unbonding-entries", false, "Count unbonding delegation entries of all the validators in /metrics/general")
+	rootCmd.PersistentFlags().IntVar(&ConcentrationTopN, "concentration-top-n", 0, "Report the supply share held by this many largest accounts in /metrics/general, 0 disables it")
+	rootCmd.PersistentFlags().StringVar(&MetricsDumpFile, "metrics-dump-file", "", "File to periodically write the metrics of all the endpoints to")
+	rootCmd.PersistentFlags().DurationVar(&MetricsDumpInterval, "metrics-dump-interval", time.Minute, "How often to write the metrics to --metrics-dump-file")
 
 	// some networks, like Iris, have the different prefixes for address, validator and consensus node
 	rootCmd.PersistentFlags().StringVar(&Prefix, "bech-prefix", "persistence", "Bech32 global prefix")
@@ -0,0 +1,91 @@
+package main
+
+import "testing"
+
+func TestParseConstLabel(t *testing.T) {
+	tests := []struct {
+		name      string
+		label     string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{
+			name:      "valid label",
+			label:     "network=mainnet",
+			wantName:  "network",
+			wantValue: "mainnet",
+		},
+		{
+			name:      "value with an equals sign",
+			label:     "region=eu=west",
+			wantName:  "region",
+			wantValue: "eu=west",
+		},
+		{
+			name:    "no value",
+			label:   "network",
+			wantErr: true,
+		},
+		{
+			name:    "empty value",
+			label:   "network=",
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			label:   "net-work=mainnet",
+			wantErr: true,
+		},
+		{
+			name:    "reserved name",
+			label:   "__name__=mainnet",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, value, err := parseConstLabel(test.label)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, test.wantErr)
+			}
+
+			if name != test.wantName || value != test.wantValue {
+				t.Errorf("got %s=%s, want %s=%s", name, value, test.wantName, test.wantValue)
+			}
+		})
+	}
+}
+
+func TestParseConstLabelRejectsExporterLabels(t *testing.T) {
+	// the labels the exporter sets on its own metrics
+	tests := []string{
+		"chain_id",
+		"address",
+		"moniker",
+		"denom",
+		"validator",
+		"handler",
+		"endpoint",
+		"query",
+		"version",
+		"commit",
+		"date",
+		"goversion",
+		"base_denom",
+		"trace_path",
+		"src_validator",
+		"dst_validator",
+		"completion_time",
+		"delegated_to",
+	}
+
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := parseConstLabel(name + "=value"); err == nil {
+				t.Errorf("got no error for the %s label", name)
+			}
+		})
+	}
+}
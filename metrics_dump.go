@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// getMetricsDumpEndpoints returns the endpoints to include into the dump. The per-wallet
+// and per-validator ones are only included if there are default addresses to query.
+func getMetricsDumpEndpoints() []string {
+	endpoints := []string{
+		"/metrics/general",
+		"/metrics/params",
+		"/metrics/validators",
+		"/metrics/proposals",
+		"/metrics/ibc",
+	}
+
+	if len(WatchedWallets) > 0 {
+		endpoints = append(endpoints, "/metrics/watched-wallets")
+	}
+
+	if DefaultValidator != "" || (DefaultToWatched && len(WatchedValidators) > 0) {
+		endpoints = append(endpoints, "/metrics/validator")
+	}
+
+	if DefaultWallet != "" || (DefaultToWatched && len(WatchedWallets) > 0) {
+		endpoints = append(endpoints, "/metrics/wallet")
+	}
+
+	return endpoints
+}
+
+// dumpMetricsPeriodically writes the metrics of all the endpoints to --metrics-dump-file
+// every --metrics-dump-interval, for the environments where Prometheus can't scrape the exporter.
+func dumpMetricsPeriodically(handler http.Handler) {
+	ticker := time.NewTicker(MetricsDumpInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		dumpStart := time.Now()
+
+		if err := dumpMetrics(handler); err != nil {
+			log.Error().Err(err).Str("file", MetricsDumpFile).Msg("Could not dump metrics")
+			continue
+		}
+
+		log.Debug().
+			Str("file", MetricsDumpFile).
+			Float64("request-time", time.Since(dumpStart).Seconds()).
+			Msg("Dumped metrics")
+	}
+}
+
+func dumpMetrics(handler http.Handler) error {
+	// every endpoint also returns the exporter metrics, so the families are merged
+	// by name, otherwise the file would have duplicates and couldn't be parsed
+	families := map[string]*dto.MetricFamily{}
+	exporterFamilies := map[string]bool{}
+
+	exporterMetrics, err := getGatherer().Gather()
+	if err != nil {
+		return err
+	}
+
+	for _, family := range exporterMetrics {
+		families[family.GetName()] = family
+		exporterFamilies[family.GetName()] = true
+	}
+
+	for _, endpoint := range getMetricsDumpEndpoints() {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, endpoint, nil))
+
+		// a single failing endpoint, like a timed out one, shouldn't leave the file stale,
+		// so it's skipped and the rest of the metrics are still written
+		if recorder.Code != http.StatusOK {
+			log.Error().
+				Str("endpoint", endpoint).
+				Int("status", recorder.Code).
+				Msg("Could not dump endpoint metrics, skipping it")
+			continue
+		}
+
+		var parser expfmt.TextParser
+		endpointFamilies, err := parser.TextToMetricFamilies(recorder.Body)
+		if err != nil {
+			log.Error().
+				Str("endpoint", endpoint).
+				Err(err).
+				Msg("Could not parse endpoint metrics, skipping it")
+			continue
+		}
+
+		for name, family := range endpointFamilies {
+			if exporterFamilies[name] {
+				continue
+			}
+
+			if existing, ok := families[name]; ok {
+				existing.Metric = append(existing.Metric, family.Metric...)
+			} else {
+				families[name] = family
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	encoder := expfmt.NewEncoder(&buffer, expfmt.FmtText)
+	for _, name := range names {
+		if err := encoder.Encode(families[name]); err != nil {
+			return err
+		}
+	}
+
+	// writing to a temporary file first, so whoever ships the file never reads a half-written one
+	tmpFile, err := os.CreateTemp(filepath.Dir(MetricsDumpFile), filepath.Base(MetricsDumpFile)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(buffer.Bytes()); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), MetricsDumpFile)
+}
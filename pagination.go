@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"google.golang.org/grpc"
+)
+
+// With --limit of 1000 that's a million items, so if it's reached,
+// the node is most likely returning the same next key over and over.
+const paginationMaxPages = 1000
+
+// paginate calls fetch with each next key, using --limit as the page size, until the node says
+// there are no more pages. If there are more than paginationMaxPages, the rest are skipped,
+// and the query is reported as truncated.
+func paginate(query string, fetch func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error)) error {
+	var nextKey []byte
+
+	for page := 1; ; page++ {
+		pageResponse, err := fetch(&querytypes.PageRequest{
+			Key:   nextKey,
+			Limit: Limit,
+		})
+		if err != nil {
+			return err
+		}
+
+		if pageResponse == nil || len(pageResponse.NextKey) == 0 {
+			setPossibleTruncation(query, false)
+			return nil
+		}
+
+		if page >= paginationMaxPages {
+			log.Warn().
+				Str("query", query).
+				Int("pages", page).
+				Msg("Query has too many pages, the rest of them are skipped")
+			setPossibleTruncation(query, true)
+			return nil
+		}
+
+		nextKey = pageResponse.NextKey
+	}
+}
+
+func queryValidators(ctx context.Context, grpcConn *grpc.ClientConn) ([]stakingtypes.Validator, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+
+	var validators []stakingtypes.Validator
+	err := paginate("validators", func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error) {
+		response, err := stakingClient.Validators(
+			ctx,
+			&stakingtypes.QueryValidatorsRequest{Pagination: pageRequest},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		validators = append(validators, response.Validators...)
+		return response.Pagination, nil
+	})
+
+	return validators, err
+}
+
+func querySigningInfos(ctx context.Context, grpcConn *grpc.ClientConn) ([]slashingtypes.ValidatorSigningInfo, error) {
+	slashingClient := slashingtypes.NewQueryClient(grpcConn)
+
+	var signingInfos []slashingtypes.ValidatorSigningInfo
+	err := paginate("signing_infos", func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error) {
+		response, err := slashingClient.SigningInfos(
+			ctx,
+			&slashingtypes.QuerySigningInfosRequest{Pagination: pageRequest},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		signingInfos = append(signingInfos, response.Info...)
+		return response.Pagination, nil
+	})
+
+	return signingInfos, err
+}
+
+func queryValidatorDelegations(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	validatorAddress string,
+) (stakingtypes.DelegationResponses, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+
+	var delegations stakingtypes.DelegationResponses
+	err := paginate("validator_delegations", func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error) {
+		response, err := stakingClient.ValidatorDelegations(
+			ctx,
+			&stakingtypes.QueryValidatorDelegationsRequest{
+				ValidatorAddr: validatorAddress,
+				Pagination:    pageRequest,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delegations = append(delegations, response.DelegationResponses...)
+		return response.Pagination, nil
+	})
+
+	return delegations, err
+}
+
+func queryValidatorUnbondingDelegations(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	validatorAddress string,
+) ([]stakingtypes.UnbondingDelegation, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+
+	var unbondings []stakingtypes.UnbondingDelegation
+	err := paginate("validator_unbonding_delegations", func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error) {
+		response, err := stakingClient.ValidatorUnbondingDelegations(
+			ctx,
+			&stakingtypes.QueryValidatorUnbondingDelegationsRequest{
+				ValidatorAddr: validatorAddress,
+				Pagination:    pageRequest,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		unbondings = append(unbondings, response.UnbondingResponses...)
+		return response.Pagination, nil
+	})
+
+	return unbondings, err
+}
+
+func queryDelegatorDelegations(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	delegatorAddress string,
+) (stakingtypes.DelegationResponses, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+
+	var delegations stakingtypes.DelegationResponses
+	err := paginate("delegator_delegations", func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error) {
+		response, err := stakingClient.DelegatorDelegations(
+			ctx,
+			&stakingtypes.QueryDelegatorDelegationsRequest{
+				DelegatorAddr: delegatorAddress,
+				Pagination:    pageRequest,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delegations = append(delegations, response.DelegationResponses...)
+		return response.Pagination, nil
+	})
+
+	return delegations, err
+}
+
+func queryDelegatorUnbondingDelegations(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	delegatorAddress string,
+) ([]stakingtypes.UnbondingDelegation, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+
+	var unbondings []stakingtypes.UnbondingDelegation
+	err := paginate("delegator_unbonding_delegations", func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error) {
+		response, err := stakingClient.DelegatorUnbondingDelegations(
+			ctx,
+			&stakingtypes.QueryDelegatorUnbondingDelegationsRequest{
+				DelegatorAddr: delegatorAddress,
+				Pagination:    pageRequest,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		unbondings = append(unbondings, response.UnbondingResponses...)
+		return response.Pagination, nil
+	})
+
+	return unbondings, err
+}
+
+// queryRedelegations returns the redelegations from the source validator or by the delegator,
+// depending on which of them is set in the request.
+func queryRedelegations(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	request stakingtypes.QueryRedelegationsRequest,
+) (stakingtypes.RedelegationResponses, error) {
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+
+	var redelegations stakingtypes.RedelegationResponses
+	err := paginate("redelegations", func(pageRequest *querytypes.PageRequest) (*querytypes.PageResponse, error) {
+		request.Pagination = pageRequest
+
+		response, err := stakingClient.Redelegations(ctx, &request)
+		if err != nil {
+			return nil, err
+		}
+
+		redelegations = append(redelegations, response.RedelegationResponses...)
+		return response.Pagination, nil
+	})
+
+	return redelegations, err
+}
@@ -11,24 +11,44 @@ import (
 	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
 	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
 )
 
 func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
 	requestStart := time.Now()
 
-	sublogger := log.With().
-		Str("request-id", uuid.New().String()).
-		Logger()
+	sublogger := zerolog.Ctx(r.Context())
+
+	registry := getParamsRegistry(r.Context(), grpcConn)
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/params").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
+
+// getParamsRegistry queries the modules params and returns the registry with them,
+// so they can be served both on /metrics/params and on /metrics.
+func getParamsRegistry(ctx context.Context, grpcConn *grpc.ClientConn) *prometheus.Registry {
+	sublogger := zerolog.Ctx(ctx)
 
 	paramsMaxValidatorsGauge := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_max_validators",
 			Help:        "Active set length",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -36,15 +56,40 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_unbonding_time",
 			Help:        "Unbonding time, in seconds",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsMaxEntriesGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_max_entries",
+			Help:        "Max amount of unbonding and redelegation entries between a delegator and a validator",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsHistoricalEntriesGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_historical_entries",
+			Help:        "Amount of historical staking info entries kept",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsBondDenomGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_bond_denom",
+			Help:        "Staking denom, value is always 1",
+			ConstLabels: getConstLabels(),
 		},
+		[]string{"denom"},
 	)
 
 	paramsBlocksPerYearGauge := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_blocks_per_year",
 			Help:        "Block per year",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -52,7 +97,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_goal_bonded",
 			Help:        "Goal bonded",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -60,7 +105,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_inflation_min",
 			Help:        "Min inflation",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -68,7 +113,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_inflation_max",
 			Help:        "Max inflation",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -76,7 +121,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_inflation_rate_change",
 			Help:        "Inflation rate change",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -84,7 +129,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_downtail_jail_duration",
 			Help:        "Downtime jail duration, in seconds",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -92,7 +137,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_min_signed_per_window",
 			Help:        "Minimal amount of blocks to sign per window to avoid slashing",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -100,7 +145,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_signed_blocks_window",
 			Help:        "Signed blocks window",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -108,7 +153,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_slash_fraction_double_sign",
 			Help:        "% of tokens to be slashed if double signing",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -116,7 +161,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_slash_fraction_downtime",
 			Help:        "% of tokens to be slashed if downtime",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -124,7 +169,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_base_proposer_reward",
 			Help:        "Base proposer reward",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 
@@ -132,20 +177,107 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_bonus_proposer_reward",
 			Help:        "Bonus proposer reward",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 	)
 	paramsCommunityTaxGauge := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_params_community_tax",
 			Help:        "Community tax",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsWithdrawAddrEnabledGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_withdraw_addr_enabled",
+			Help:        "1 if the delegators can set a separate rewards withdraw address, 0 if no",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsGovMinDepositGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_gov_min_deposit",
+			Help:        "Min deposit for a proposal to enter the voting period",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"denom"},
+	)
+
+	paramsGovMaxDepositPeriodGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_gov_max_deposit_period",
+			Help:        "Max time to reach the min deposit, in seconds",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsGovVotingPeriodGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_gov_voting_period",
+			Help:        "Voting period, in seconds",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsGovQuorumGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_gov_quorum",
+			Help:        "Min share of the voting power that should vote for the proposal result to be valid",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsGovThresholdGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_gov_threshold",
+			Help:        "Min share of yes votes, not counting abstain, for the proposal to pass",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsGovVetoThresholdGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_gov_veto_threshold",
+			Help:        "Min share of no with veto votes for the proposal to be vetoed",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	paramsEpochReductionFactorGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_epoch_reduction_factor",
+			Help:        "Factor epoch provisions are multiplied by every reduction period, for chains with epoch-based minting",
+			ConstLabels: getConstLabels(),
 		},
+		[]string{"epoch_identifier"},
+	)
+
+	paramsEpochReductionPeriodGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_epoch_reduction_period",
+			Help:        "Amount of epochs between epoch provisions reductions, for chains with epoch-based minting",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"epoch_identifier"},
+	)
+
+	paramsEpochStakingProportionGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_params_epoch_staking_distribution_proportion",
+			Help:        "Proportion of epoch provisions going to stakers, for chains with epoch-based minting",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"epoch_identifier"},
 	)
 
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(paramsMaxValidatorsGauge)
 	registry.MustRegister(paramsUnbondingTimeGauge)
+	registry.MustRegister(paramsMaxEntriesGauge)
+	registry.MustRegister(paramsHistoricalEntriesGauge)
+	registry.MustRegister(paramsBondDenomGauge)
 	registry.MustRegister(paramsBlocksPerYearGauge)
 	registry.MustRegister(paramsInflationMinGauge)
 	registry.MustRegister(paramsInflationMaxGauge)
@@ -158,9 +290,20 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 	registry.MustRegister(paramsBaseProposerRewardGauge)
 	registry.MustRegister(paramsBonusProposerRewardGauge)
 	registry.MustRegister(paramsCommunityTaxGauge)
+	registry.MustRegister(paramsWithdrawAddrEnabledGauge)
+	registry.MustRegister(paramsGovMinDepositGauge)
+	registry.MustRegister(paramsGovMaxDepositPeriodGauge)
+	registry.MustRegister(paramsGovVotingPeriodGauge)
+	registry.MustRegister(paramsGovQuorumGauge)
+	registry.MustRegister(paramsGovThresholdGauge)
+	registry.MustRegister(paramsGovVetoThresholdGauge)
+	registry.MustRegister(paramsEpochReductionFactorGauge)
+	registry.MustRegister(paramsEpochReductionPeriodGauge)
+	registry.MustRegister(paramsEpochStakingProportionGauge)
 
 	var wg sync.WaitGroup
 
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		sublogger.Debug().Msg("Started querying global staking params")
@@ -168,7 +311,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 
 		stakingClient := stakingtypes.NewQueryClient(grpcConn)
 		paramsResponse, err := stakingClient.Params(
-			context.Background(),
+			ctx,
 			&stakingtypes.QueryParamsRequest{},
 		)
 		if err != nil {
@@ -184,9 +327,14 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 
 		paramsMaxValidatorsGauge.Set(float64(paramsResponse.Params.MaxValidators))
 		paramsUnbondingTimeGauge.Set(paramsResponse.Params.UnbondingTime.Seconds())
+		paramsMaxEntriesGauge.Set(float64(paramsResponse.Params.MaxEntries))
+		paramsHistoricalEntriesGauge.Set(float64(paramsResponse.Params.HistoricalEntries))
+		paramsBondDenomGauge.With(prometheus.Labels{
+			"denom": paramsResponse.Params.BondDenom,
+		}).Set(1)
 	}()
-	wg.Add(1)
 
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		sublogger.Debug().Msg("Started querying global mint params")
@@ -194,7 +342,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 
 		mintClient := minttypes.NewQueryClient(grpcConn)
 		paramsResponse, err := mintClient.Params(
-			context.Background(),
+			ctx,
 			&minttypes.QueryParamsRequest{},
 		)
 		if err != nil {
@@ -243,8 +391,8 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 			paramsInflationRateChangeGauge.Set(value)
 		}
 	}()
-	wg.Add(1)
 
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		sublogger.Debug().Msg("Started querying global slashing params")
@@ -252,7 +400,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 
 		slashingClient := slashingtypes.NewQueryClient(grpcConn)
 		paramsResponse, err := slashingClient.Params(
-			context.Background(),
+			ctx,
 			&slashingtypes.QueryParamsRequest{},
 		)
 		if err != nil {
@@ -293,8 +441,8 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 			paramsSlashFractionDowntime.Set(value)
 		}
 	}()
-	wg.Add(1)
 
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		sublogger.Debug().Msg("Started querying global distribution params")
@@ -302,7 +450,7 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 
 		distributionClient := distributiontypes.NewQueryClient(grpcConn)
 		paramsResponse, err := distributionClient.Params(
-			context.Background(),
+			ctx,
 			&distributiontypes.QueryParamsRequest{},
 		)
 		if err != nil {
@@ -340,16 +488,120 @@ func ParamsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		} else {
 			paramsCommunityTaxGauge.Set(value)
 		}
+
+		if paramsResponse.Params.WithdrawAddrEnabled {
+			paramsWithdrawAddrEnabledGauge.Set(1)
+		} else {
+			paramsWithdrawAddrEnabledGauge.Set(0)
+		}
 	}()
+
 	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sublogger.Debug().Msg("Started querying global gov params")
+		queryStart := time.Now()
+
+		params, err := queryGovParams(ctx, grpcConn)
+		if err != nil {
+			sublogger.Error().
+				Err(err).
+				Msg("Could not get global gov params")
+			return
+		}
+
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying global gov params")
+
+		paramsGovMaxDepositPeriodGauge.Set(params.MaxDepositPeriod.Seconds())
+		paramsGovVotingPeriodGauge.Set(params.VotingPeriod.Seconds())
+
+		for _, coin := range params.MinDeposit {
+			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+			if value, err := strconv.ParseFloat(coin.Amount, 64); err != nil {
+				sublogger.Error().
+					Err(err).
+					Msg("Could not parse min deposit")
+			} else {
+				denom, amount := convertCoin(coin.Denom, value)
+				paramsGovMinDepositGauge.With(prometheus.Labels{
+					"denom": denom,
+				}).Set(amount)
+			}
+		}
+
+		if value, err := strconv.ParseFloat(params.Quorum, 64); err != nil {
+			sublogger.Error().
+				Err(err).
+				Msg("Could not parse quorum")
+		} else {
+			paramsGovQuorumGauge.Set(value)
+		}
+
+		if value, err := strconv.ParseFloat(params.Threshold, 64); err != nil {
+			sublogger.Error().
+				Err(err).
+				Msg("Could not parse threshold")
+		} else {
+			paramsGovThresholdGauge.Set(value)
+		}
+
+		if value, err := strconv.ParseFloat(params.VetoThreshold, 64); err != nil {
+			sublogger.Error().
+				Err(err).
+				Msg("Could not parse veto threshold")
+		} else {
+			paramsGovVetoThresholdGauge.Set(value)
+		}
+	}()
+
+	if EpochMintAvailable {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().Msg("Started querying epoch mint params")
+			queryStart := time.Now()
+
+			params, err := queryEpochMintParams(ctx, grpcConn)
+			if err != nil {
+				sublogger.Error().
+					Err(err).
+					Msg("Could not get epoch mint params")
+				return
+			}
+
+			sublogger.Debug().
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying epoch mint params")
+
+			labels := prometheus.Labels{"epoch_identifier": params.EpochIdentifier}
+
+			paramsEpochReductionPeriodGauge.With(labels).Set(float64(params.ReductionPeriodInEpochs))
+
+			if value, err := parseEpochMintDec(params.ReductionFactor); err != nil {
+				sublogger.Error().
+					Err(err).
+					Msg("Could not parse reduction factor")
+			} else {
+				paramsEpochReductionFactorGauge.With(labels).Set(value)
+			}
+
+			if params.DistributionProportions == nil {
+				return
+			}
+
+			if value, err := parseEpochMintDec(params.DistributionProportions.Staking); err != nil {
+				sublogger.Error().
+					Err(err).
+					Msg("Could not parse staking distribution proportion")
+			} else {
+				paramsEpochStakingProportionGauge.With(labels).Set(value)
+			}
+		}()
+	}
 
 	wg.Wait()
 
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
-	sublogger.Info().
-		Str("method", "GET").
-		Str("endpoint", "/metrics/params").
-		Float64("request-time", time.Since(requestStart).Seconds()).
-		Msg("Request processed")
+	return registry
 }
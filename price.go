@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	priceSourceCoingecko = "coingecko"
+	coingeckoPriceURL    = "https://api.coingecko.com/api/v3/simple/price"
+	priceQueryTimeout    = 10 * time.Second
+)
+
+// the price is refreshed in the background, so the scrapes don't wait for the price source
+var (
+	usdPrice      float64
+	usdPriceKnown bool
+	usdPriceMutex sync.RWMutex
+)
+
+func refreshPricePeriodically() {
+	ticker := time.NewTicker(PriceRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		refreshPrice()
+		<-ticker.C
+	}
+}
+
+func refreshPrice() {
+	price, err := queryCoingeckoPrice(CoingeckoID)
+
+	usdPriceMutex.Lock()
+	defer usdPriceMutex.Unlock()
+
+	// a stale price is worse than no price, so the USD metrics are not reported until it's back
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("--coingecko-id", CoingeckoID).
+			Msg("Could not get price, not reporting USD metrics")
+		usdPriceKnown = false
+		return
+	}
+
+	log.Debug().Float64("price", price).Msg("Got price")
+	usdPrice = price
+	usdPriceKnown = true
+}
+
+func queryCoingeckoPrice(id string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), priceQueryTimeout)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("ids", id)
+	query.Set("vs_currencies", "usd")
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, coingeckoPriceURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("coingecko returned %s", response.Status)
+	}
+
+	// {"cosmos":{"usd":12.34}}
+	var prices map[string]map[string]float64
+	if err := json.NewDecoder(response.Body).Decode(&prices); err != nil {
+		return 0, err
+	}
+
+	price, found := prices[id]["usd"]
+	if !found {
+		return 0, fmt.Errorf("coingecko returned no price for %s", id)
+	}
+
+	return price, nil
+}
+
+// getUSDValue returns the value of the amount in USD. The price is only known for --denom,
+// so the amounts in the other denoms, or if the price could not be fetched, are not valued.
+func getUSDValue(denom string, amount float64) (float64, bool) {
+	if PriceSource == "" || denom != Denom {
+		return 0, false
+	}
+
+	usdPriceMutex.RLock()
+	defer usdPriceMutex.RUnlock()
+
+	if !usdPriceKnown {
+		return 0, false
+	}
+
+	return amount * usdPrice, true
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// gov v1 and v1beta1 name the proposal statuses differently, so to not break dashboards
+// when a chain upgrades from one to another, the status is always reported with these labels.
+var proposalStatusLabels = map[int32]string{
+	0: "unspecified",
+	1: "deposit_period",
+	2: "voting_period",
+	3: "passed",
+	4: "rejected",
+	5: "failed",
+}
+
+func getProposalStatusLabel(status int32) string {
+	if label, ok := proposalStatusLabels[status]; ok {
+		return label
+	}
+
+	return "unknown_" + strconv.Itoa(int(status))
+}
+
+func ProposalsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := zerolog.Ctx(r.Context())
+
+	ctx := r.Context()
+
+	proposalsCountGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_proposals_count",
+			Help:        "Amount of governance proposals by status",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"status"},
+	)
+
+	proposalsInfoGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_proposals_info",
+			Help:        "Governance proposal info, value is always 1",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"proposal_id", "status", "type", "title"},
+	)
+
+	proposalsTallyGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_proposals_tally",
+			Help:        "Tokens voted for each option of the governance proposal, the current tally for the proposals in voting period",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"proposal_id", "option", "denom"},
+	)
+
+	proposalsVotingStartTimeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_proposals_voting_start_time",
+			Help:        "Unix timestamp of the governance proposal voting period start",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"proposal_id"},
+	)
+
+	proposalsVotingEndTimeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_proposals_voting_end_time",
+			Help:        "Unix timestamp of the governance proposal voting period end",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"proposal_id"},
+	)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(proposalsCountGauge)
+	registry.MustRegister(proposalsInfoGauge)
+	registry.MustRegister(proposalsTallyGauge)
+	registry.MustRegister(proposalsVotingStartTimeGauge)
+	registry.MustRegister(proposalsVotingEndTimeGauge)
+
+	sublogger.Debug().Msg("Started querying proposals")
+	queryStart := time.Now()
+
+	proposals, err := queryProposals(ctx, grpcConn)
+	if err != nil {
+		sublogger.Error().Err(err).Msg("Could not get proposals")
+	} else {
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying proposals")
+		checkPossibleTruncation("proposals", len(proposals))
+	}
+
+	govClient := govtypes.NewQueryClient(grpcConn)
+
+	for _, proposal := range proposals {
+		proposalID := strconv.FormatUint(proposal.ID, 10)
+
+		proposalsCountGauge.With(prometheus.Labels{
+			"status": getProposalStatusLabel(proposal.Status),
+		}).Inc()
+
+		proposalsInfoGauge.With(prometheus.Labels{
+			"proposal_id": proposalID,
+			"status":      getProposalStatusLabel(proposal.Status),
+			"type":        proposal.Type,
+			"title":       proposal.Title,
+		}).Set(1)
+
+		// the voting period times are zero while the proposal is in the deposit period
+		if !proposal.VotingStartTime.IsZero() && proposal.VotingStartTime.Unix() > 0 {
+			proposalsVotingStartTimeGauge.With(prometheus.Labels{
+				"proposal_id": proposalID,
+			}).Set(float64(proposal.VotingStartTime.Unix()))
+		}
+
+		if !proposal.VotingEndTime.IsZero() && proposal.VotingEndTime.Unix() > 0 {
+			proposalsVotingEndTimeGauge.With(prometheus.Labels{
+				"proposal_id": proposalID,
+			}).Set(float64(proposal.VotingEndTime.Unix()))
+		}
+
+		tally := proposal.FinalTally
+
+		// the final tally is only calculated when the voting period ends, so the current one is queried
+		if proposal.Status == proposalStatusVotingPeriod {
+			sublogger.Debug().
+				Str("proposal_id", proposalID).
+				Msg("Started querying proposal tally")
+			queryStart := time.Now()
+
+			tallyResponse, err := govClient.TallyResult(
+				ctx,
+				&govtypes.QueryTallyResultRequest{ProposalId: proposal.ID},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("proposal_id", proposalID).
+					Err(err).
+					Msg("Could not get proposal tally")
+				continue
+			}
+
+			sublogger.Debug().
+				Str("proposal_id", proposalID).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying proposal tally")
+
+			tally = map[string]string{
+				"yes":          tallyResponse.Tally.Yes.String(),
+				"no":           tallyResponse.Tally.No.String(),
+				"no_with_veto": tallyResponse.Tally.NoWithVeto.String(),
+				"abstain":      tallyResponse.Tally.Abstain.String(),
+			}
+		}
+
+		for option, amount := range tally {
+			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+			if value, err := strconv.ParseFloat(amount, 64); err != nil {
+				sublogger.Error().
+					Str("proposal_id", proposalID).
+					Str("option", option).
+					Err(err).
+					Msg("Could not parse proposal tally")
+			} else {
+				proposalsTallyGauge.With(prometheus.Labels{
+					"proposal_id": proposalID,
+					"option":      option,
+					"denom":       Denom,
+				}).Set(value / DenomCoefficient)
+			}
+		}
+	}
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/proposals").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// getRemoteAddress returns the IP of the client that sent the request. If --trust-proxy
+// is set, X-Forwarded-For is walked from the right, skipping the hops that are listed
+// in --trusted-proxies, and the first untrusted one is returned. Taking the leftmost
+// address instead would allow the client to spoof it by sending its own header.
+func getRemoteAddress(r *http.Request) string {
+	remoteAddress := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remoteAddress = host
+	}
+
+	if !TrustProxy {
+		return remoteAddress
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	if len(hops) == 0 {
+		return remoteAddress
+	}
+
+	for index := len(hops) - 1; index >= 0; index-- {
+		ip := net.ParseIP(hops[index])
+		if ip == nil {
+			// garbage in the header, can't trust anything to the left of it
+			return remoteAddress
+		}
+
+		if index == 0 || !isTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+
+	return remoteAddress
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+type cachedResponse struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+	Time   time.Time
+}
+
+// responseCacheCall is a request that is being processed, so the same requests
+// made at the same time wait for it instead of querying the node themselves.
+type responseCacheCall struct {
+	wg       sync.WaitGroup
+	response *cachedResponse
+}
+
+var (
+	responseCache         = map[string]*cachedResponse{}
+	responseCacheInFlight = map[string]*responseCacheCall{}
+	responseCacheMutex    sync.Mutex
+)
+
+// getResponseCacheKey returns the key to cache the response with. The query params are sorted,
+// so the order they're passed in doesn't matter. The headers the response format depends on
+// are included, as /metrics/general can return OpenMetrics, and the response can be gzipped.
+func getResponseCacheKey(r *http.Request) string {
+	return r.URL.Path + "?" + r.URL.Query().Encode() +
+		"|" + r.Header.Get("Accept") +
+		"|" + r.Header.Get("Accept-Encoding")
+}
+
+// serveCached serves the response rendered by the handler within the last --cache-ttl,
+// if there's one, instead of querying the node again.
+func serveCached(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	if CacheTTL <= 0 {
+		handler(w, r)
+		return
+	}
+
+	sublogger := zerolog.Ctx(r.Context())
+	key := getResponseCacheKey(r)
+
+	responseCacheMutex.Lock()
+	if cached, found := responseCache[key]; found && time.Since(cached.Time) < CacheTTL {
+		responseCacheMutex.Unlock()
+
+		sublogger.Debug().
+			Str("key", key).
+			Time("cached-at", cached.Time).
+			Msg("Serving cached response")
+		exporterCacheHitsCounter.With(prometheus.Labels{"endpoint": r.URL.Path}).Inc()
+		writeCachedResponse(w, cached)
+		return
+	}
+
+	if call, found := responseCacheInFlight[key]; found {
+		responseCacheMutex.Unlock()
+		call.wg.Wait()
+
+		// if the request being waited for was cancelled, there's nothing to share
+		if call.response != nil {
+			sublogger.Debug().
+				Str("key", key).
+				Msg("Serving response of the same request made concurrently")
+			exporterCacheHitsCounter.With(prometheus.Labels{"endpoint": r.URL.Path}).Inc()
+			writeCachedResponse(w, call.response)
+			return
+		}
+
+		handler(w, r)
+		return
+	}
+
+	call := &responseCacheCall{}
+	call.wg.Add(1)
+	responseCacheInFlight[key] = call
+	responseCacheMutex.Unlock()
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, r)
+
+	response := &cachedResponse{
+		Code:   recorder.Code,
+		Header: recorder.Header(),
+		Body:   recorder.Body.Bytes(),
+		Time:   time.Now(),
+	}
+
+	responseCacheMutex.Lock()
+	delete(responseCacheInFlight, key)
+	if r.Context().Err() == nil {
+		call.response = response
+
+		// errors are shared with the concurrent requests, but not cached,
+		// so the next scrape tries again
+		if response.Code == http.StatusOK {
+			pruneResponseCache()
+			responseCache[key] = response
+		}
+	}
+	responseCacheMutex.Unlock()
+	call.wg.Done()
+
+	writeCachedResponse(w, response)
+}
+
+// pruneResponseCache removes the expired responses, so the cache doesn't grow forever
+// if the endpoints are queried with a lot of different params. Should be called with the mutex locked.
+func pruneResponseCache() {
+	for key, cached := range responseCache {
+		if time.Since(cached.Time) >= CacheTTL {
+			delete(responseCache, key)
+		}
+	}
+}
+
+func writeCachedResponse(w http.ResponseWriter, response *cachedResponse) {
+	for name, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	w.WriteHeader(response.Code)
+	w.Write(response.Body)
+}
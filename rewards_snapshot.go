@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	distributiontypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// getHeightContext returns the context making the gRPC node answer the query
+// as of the given block instead of the latest one.
+func getHeightContext(ctx context.Context, height int64) context.Context {
+	return metadata.AppendToOutgoingContext(
+		ctx,
+		grpctypes.GRPCBlockHeightHeader,
+		strconv.FormatInt(height, 10),
+	)
+}
+
+// isHeightPrunedError checks whether the node could not answer the query as it
+// doesn't have the state for this height anymore. There's no specific error code for it,
+// so the only way is to check the message.
+func isHeightPrunedError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "version does not exist") ||
+		strings.Contains(message, "pruned")
+}
+
+func RewardsSnapshotHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := zerolog.Ctx(r.Context())
+
+	ctx := r.Context()
+
+	address := r.URL.Query().Get("address")
+	myAddress, err := sdk.AccAddressFromBech32(address)
+	if err != nil {
+		sublogger.Error().
+			Str("address", address).
+			Err(err).
+			Msg("Could not get address")
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	height, err := strconv.ParseInt(r.URL.Query().Get("height"), 10, 64)
+	if err != nil || height <= 0 {
+		sublogger.Error().
+			Str("height", r.URL.Query().Get("height")).
+			Msg("Could not parse height")
+		http.Error(w, "Height should be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	heightLabel := strconv.FormatInt(height, 10)
+
+	rewardsSnapshotRewardsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_rewards_snapshot_rewards",
+			Help:        "Rewards of the Cosmos-based blockchain wallet as of the given height",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom", "validator_address", "height"},
+	)
+
+	rewardsSnapshotCommissionGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_rewards_snapshot_commission",
+			Help:        "Commission of the Cosmos-based blockchain validator owned by the wallet as of the given height",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom", "height"},
+	)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(rewardsSnapshotRewardsGauge)
+	registry.MustRegister(rewardsSnapshotCommissionGauge)
+
+	distributionClient := distributiontypes.NewQueryClient(grpcConn)
+
+	sublogger.Debug().
+		Str("address", address).
+		Int64("height", height).
+		Msg("Started querying rewards snapshot")
+	queryStart := time.Now()
+
+	rewardsRes, err := distributionClient.DelegationTotalRewards(
+		getHeightContext(ctx, height),
+		&distributiontypes.QueryDelegationTotalRewardsRequest{DelegatorAddress: myAddress.String()},
+	)
+	if err != nil {
+		sublogger.Error().
+			Str("address", address).
+			Int64("height", height).
+			Err(err).
+			Msg("Could not get rewards snapshot")
+
+		if handleQueryTimeout(w, r) {
+			return
+		} else if isHeightPrunedError(err) {
+			http.Error(w, "The node doesn't have the state for this height, it's probably pruned", http.StatusNotFound)
+		} else {
+			http.Error(w, "Could not get rewards", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	for _, reward := range rewardsRes.Rewards {
+		for _, entry := range reward.Reward {
+			if !isRewardsDenomAllowed(entry.Denom) {
+				continue
+			}
+
+			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+			if value, err := strconv.ParseFloat(entry.Amount.String(), 64); err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get reward")
+			} else {
+				denom, amount := convertCoin(entry.Denom, value)
+				rewardsSnapshotRewardsGauge.With(prometheus.Labels{
+					"address":           address,
+					"denom":             denom,
+					"validator_address": reward.ValidatorAddress,
+					"height":            heightLabel,
+				}).Set(amount)
+			}
+		}
+	}
+
+	// the wallet may be a validator's self-delegate address, then its commission is also needed
+	commissionRes, err := distributionClient.ValidatorCommission(
+		getHeightContext(ctx, height),
+		&distributiontypes.QueryValidatorCommissionRequest{ValidatorAddress: sdk.ValAddress(myAddress).String()},
+	)
+	if err != nil {
+		sublogger.Debug().
+			Str("address", address).
+			Int64("height", height).
+			Err(err).
+			Msg("Could not get commission snapshot, the wallet is probably not a validator")
+	} else {
+		for _, commission := range commissionRes.Commission.Commission {
+			if !isRewardsDenomAllowed(commission.Denom) {
+				continue
+			}
+
+			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+			if value, err := strconv.ParseFloat(commission.Amount.String(), 64); err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get commission")
+			} else {
+				denom, amount := convertCoin(commission.Denom, value)
+				rewardsSnapshotCommissionGauge.With(prometheus.Labels{
+					"address": address,
+					"denom":   denom,
+					"height":  heightLabel,
+				}).Set(amount)
+			}
+		}
+	}
+
+	sublogger.Debug().
+		Str("address", address).
+		Int64("height", height).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying rewards snapshot")
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/rewards-snapshot").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type sample struct {
+	Value float64
+	Time  time.Time
+}
+
+// sampleStore keeps the last observed value for metrics that are calculated as a difference
+// between two scrapes, as Prometheus doesn't give us the previous value.
+type sampleStore struct {
+	mutex     sync.Mutex
+	samples   map[string]sample
+	averages  map[string]float64
+	lastPrune time.Time
+}
+
+// samples older than this are dropped, otherwise querying a lot of different
+// wallets or validators once would make the store grow forever
+const sampleTTL = time.Hour
+
+var samples = newSampleStore()
+
+func newSampleStore() *sampleStore {
+	return &sampleStore{
+		samples:  map[string]sample{},
+		averages: map[string]float64{},
+	}
+}
+
+// Observe stores the value for the key and returns the previously stored sample, if any.
+func (s *sampleStore) Observe(key string, value float64, t time.Time) (sample, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.prune(t)
+
+	previous, found := s.samples[key]
+	s.samples[key] = sample{Value: value, Time: t}
+	return previous, found
+}
+
+// Delta stores the value for the key and returns how much it has changed since the previously
+// stored sample and how much time has passed since then. If there's no previous sample, false is returned.
+func (s *sampleStore) Delta(key string, value float64, t time.Time) (float64, time.Duration, bool) {
+	previous, found := s.Observe(key, value, t)
+	if !found {
+		return 0, 0, false
+	}
+
+	return value - previous.Value, t.Sub(previous.Time), true
+}
+
+// prune should be called with the mutex locked. It only checks the samples once per sampleTTL,
+// so it's cheap enough to call it on every observation.
+func (s *sampleStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < sampleTTL {
+		return
+	}
+
+	s.lastPrune = now
+
+	for key, sample := range s.samples {
+		if now.Sub(sample.Time) > sampleTTL {
+			delete(s.samples, key)
+			delete(s.averages, key)
+		}
+	}
+}
+
+// Smooth returns the exponential moving average of the values passed for the key,
+// or the value itself if --ema-alpha is not set.
+func (s *sampleStore) Smooth(key string, value float64) float64 {
+	if EmaAlpha == 0 {
+		return value
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	average, found := s.averages[key]
+	if found {
+		average = EmaAlpha*value + (1-EmaAlpha)*average
+	} else {
+		average = value
+	}
+
+	s.averages[key] = average
+	return average
+}
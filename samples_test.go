@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSampleStoreDelta(t *testing.T) {
+	store := newSampleStore()
+	start := time.Now()
+
+	tests := []struct {
+		name      string
+		value     float64
+		time      time.Time
+		wantDelta float64
+		wantSince time.Duration
+		wantFound bool
+	}{
+		{
+			name:      "first sample",
+			value:     10,
+			time:      start,
+			wantFound: false,
+		},
+		{
+			name:      "value increased",
+			value:     15,
+			time:      start.Add(time.Minute),
+			wantDelta: 5,
+			wantSince: time.Minute,
+			wantFound: true,
+		},
+		{
+			name:      "value decreased",
+			value:     12,
+			time:      start.Add(3 * time.Minute),
+			wantDelta: -3,
+			wantSince: 2 * time.Minute,
+			wantFound: true,
+		},
+		{
+			name:      "previous sample is stale",
+			value:     20,
+			time:      start.Add(3*time.Minute + 2*sampleTTL),
+			wantFound: false,
+		},
+	}
+
+	for _, test := range tests {
+		delta, since, found := store.Delta("key", test.value, test.time)
+		if found != test.wantFound {
+			t.Fatalf("%s: got found %v, want %v", test.name, found, test.wantFound)
+		}
+
+		if delta != test.wantDelta || since != test.wantSince {
+			t.Errorf("%s: got delta %v over %v, want %v over %v", test.name, delta, since, test.wantDelta, test.wantSince)
+		}
+	}
+}
+
+func TestSampleStoreConcurrentAccess(t *testing.T) {
+	store := newSampleStore()
+	start := time.Now()
+
+	const (
+		keys         = 10
+		observations = 100
+	)
+
+	var wg sync.WaitGroup
+
+	// every key has its own goroutine doing Delta, while the others call Observe on the same keys,
+	// so the test is meant to be run with -race
+	for key := 0; key < keys; key++ {
+		wg.Add(2)
+
+		go func(key string) {
+			defer wg.Done()
+
+			for index := 0; index < observations; index++ {
+				store.Delta(key, float64(index), start.Add(time.Duration(index)*time.Second))
+			}
+		}(fmt.Sprintf("delta-%d", key))
+
+		go func(key string) {
+			defer wg.Done()
+
+			for index := 0; index < observations; index++ {
+				store.Observe(key, float64(index), start.Add(time.Duration(index)*time.Second))
+			}
+		}(fmt.Sprintf("delta-%d", key))
+	}
+
+	wg.Wait()
+
+	for key := 0; key < keys; key++ {
+		previous, found := store.Observe(fmt.Sprintf("delta-%d", key), 0, start.Add(observations*time.Second))
+		if !found {
+			t.Fatalf("sample for key %d is lost", key)
+		}
+
+		if previous.Value != observations-1 {
+			t.Errorf("got last value %v for key %d, want %v", previous.Value, key, observations-1)
+		}
+	}
+}
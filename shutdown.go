@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
+)
+
+// shutdownOnSignal waits for SIGINT or SIGTERM, then stops accepting new scrapes, waits
+// up to --shutdown-timeout for the in-flight ones to finish, so they are not cut off
+// on rolling updates, and closes the gRPC connection. done is closed once it's all finished.
+func shutdownOnSignal(server *http.Server, grpcConn *grpc.ClientConn, done chan<- struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	received := <-signals
+	log.Info().
+		Str("signal", received.String()).
+		Dur("--shutdown-timeout", ShutdownTimeout).
+		Msg("Shutting down, waiting for in-flight scrapes to finish")
+
+	// restoring the default behaviour, so the second signal kills the exporter without waiting
+	signal.Stop(signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Could not finish in-flight scrapes in time, closing them")
+		server.Close()
+	}
+
+	if err := grpcConn.Close(); err != nil {
+		log.Warn().Err(err).Msg("Could not close gRPC connection")
+	}
+
+	close(done)
+}
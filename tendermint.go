@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	tmrpc "github.com/tendermint/tendermint/rpc/client/http"
+	jsonrpcclient "github.com/tendermint/tendermint/rpc/jsonrpc/client"
+)
+
+const rpcRetryDelay = 500 * time.Millisecond
+
+// Tendermint returns at most this many blocks in a single /blockchain request.
+const blockchainInfoMaxBlocks = 20
+
+// the blocks are sampled on every /metrics/validator scrape, and this is already 500 /blockchain requests
+const maxProposerSampleSize = 10000
+
+// set by initTendermintEndpoints on startup
+var (
+	tendermintEndpoints []*tendermintEndpoint
+	tendermintTransport http.RoundTripper
+)
+
+type tendermintEndpoint struct {
+	// the configured address without the credentials, to be used in logs and labels
+	Address   string
+	URL       *url.URL
+	User      *url.Userinfo
+	Transport http.RoundTripper
+}
+
+// initTendermintEndpoints parses the --tendermint-rpc addresses. Each of them gets its own transport,
+// as the Tendermint one is bound to the address it dials.
+func initTendermintEndpoints() error {
+	for _, address := range TendermintRPCs {
+		parsed, err := url.Parse(address)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", address, err)
+		}
+
+		httpClient, err := jsonrpcclient.DefaultHTTPClient(address)
+		if err != nil {
+			return fmt.Errorf("could not create client for %s: %w", address, err)
+		}
+
+		endpoint := &tendermintEndpoint{
+			Address:   parsed.Redacted(),
+			URL:       getTendermintRequestURL(parsed),
+			User:      parsed.User,
+			Transport: &retryingTransport{next: httpClient.Transport},
+		}
+
+		tendermintEndpoints = append(tendermintEndpoints, endpoint)
+	}
+
+	if len(tendermintEndpoints) == 0 {
+		return fmt.Errorf("no Tendermint RPC addresses provided")
+	}
+
+	tendermintTransport = &failoverTransport{endpoints: tendermintEndpoints}
+	return nil
+}
+
+// getTendermintRequestURL returns the URL the Tendermint client would send the requests
+// for this address to: tcp:// is an alias for http://, and for unix sockets the socket path
+// is used as the host, as the connection is made to the socket anyway.
+func getTendermintRequestURL(address *url.URL) *url.URL {
+	requestURL := *address
+	requestURL.User = nil
+
+	switch requestURL.Scheme {
+	case "https":
+	case "unix":
+		requestURL.Scheme = "http"
+		requestURL.Host = strings.ReplaceAll(requestURL.Host+requestURL.EscapedPath(), "/", ".")
+		requestURL.Path = ""
+		requestURL.RawPath = ""
+	default:
+		requestURL.Scheme = "http"
+	}
+
+	return &requestURL
+}
+
+// newTendermintClient should be used instead of creating the Tendermint RPC client directly,
+// as the default one has no timeout and would hang forever if the node doesn't respond,
+// and only knows about one address.
+func newTendermintClient() (*tmrpc.HTTP, error) {
+	httpClient := &http.Client{Transport: tendermintTransport}
+	return tmrpc.NewWithClient(TendermintRPCs[0], "/websocket", httpClient)
+}
+
+// failoverTransport sends the requests to the endpoint that responded last, and if it fails,
+// to the other ones in the order they were configured. --rpc-timeout applies to each of them,
+// so a hanging endpoint doesn't eat up the time for the other ones.
+type failoverTransport struct {
+	endpoints []*tendermintEndpoint
+}
+
+// the index of the endpoint the requests are sent to first
+var activeTendermintEndpoint int32
+
+func (t *failoverTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	active := int(atomic.LoadInt32(&activeTendermintEndpoint))
+
+	var problems []string
+	var lastResponse *http.Response
+
+	for offset := range t.endpoints {
+		index := (active + offset) % len(t.endpoints)
+		endpoint := t.endpoints[index]
+
+		response, err := t.roundTripEndpoint(request, endpoint)
+		if !isEndpointFailure(response, err) {
+			if lastResponse != nil {
+				lastResponse.Body.Close()
+			}
+
+			if index != active {
+				log.Warn().
+					Str("endpoint", endpoint.Address).
+					Strs("problems", problems).
+					Msg("Switched to another Tendermint RPC endpoint")
+				atomic.StoreInt32(&activeTendermintEndpoint, int32(index))
+				setTendermintEndpointActive(index)
+			}
+
+			return response, nil
+		}
+
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", endpoint.Address, err))
+			continue
+		}
+
+		problems = append(problems, fmt.Sprintf("%s: %s", endpoint.Address, response.Status))
+
+		// Tendermint returns the query errors as 500, so if none of the endpoints succeeds,
+		// such a response is returned for the error to be parsed by the client.
+		// Other codes come from the proxies in front of the node and mean nothing to the client.
+		if response.StatusCode != http.StatusInternalServerError {
+			response.Body.Close()
+			continue
+		}
+
+		if lastResponse != nil {
+			lastResponse.Body.Close()
+		}
+		lastResponse = response
+	}
+
+	if lastResponse != nil {
+		log.Debug().Strs("problems", problems).Msg("All Tendermint RPC endpoints returned an error")
+		return lastResponse, nil
+	}
+
+	return nil, fmt.Errorf("all Tendermint RPC endpoints failed: %s", strings.Join(problems, "; "))
+}
+
+func (t *failoverTransport) roundTripEndpoint(request *http.Request, endpoint *tendermintEndpoint) (*http.Response, error) {
+	ctx := request.Context()
+	cancel := context.CancelFunc(func() {})
+	if RPCTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, RPCTimeout)
+	}
+
+	endpointRequest := request.Clone(ctx)
+	endpointRequest.URL = endpoint.URL
+	endpointRequest.Host = ""
+
+	// the client sets the credentials of the first address, which shouldn't be sent to the other ones
+	endpointRequest.Header.Del("Authorization")
+	if endpoint.User != nil {
+		password, _ := endpoint.User.Password()
+		endpointRequest.SetBasicAuth(endpoint.User.Username(), password)
+	}
+
+	// the body was consumed by the previous endpoint, so it has to be re-created
+	if request.Body != nil && request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		endpointRequest.Body = body
+	}
+
+	response, err := endpoint.Transport.RoundTrip(endpointRequest)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the timeout should also apply to reading the body, so it's only cancelled once it's closed
+	response.Body = &cancelOnCloseBody{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryingTransport retries the requests that failed because of a network error
+// or a 502, 503 or 504 response --rpc-retries times. The retries count towards --rpc-timeout.
+type retryingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *retryingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.next.RoundTrip(request)
+
+	for attempt := 1; attempt <= RPCRetries && isRetryable(response, err); attempt++ {
+		// the body was consumed by the previous attempt, so it has to be re-created
+		if request.Body != nil {
+			if request.GetBody == nil {
+				return response, err
+			}
+
+			body, bodyErr := request.GetBody()
+			if bodyErr != nil {
+				return response, err
+			}
+			request.Body = body
+		}
+
+		if response != nil {
+			response.Body.Close()
+		}
+
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(rpcRetryDelay * time.Duration(attempt)):
+		}
+
+		log.Debug().
+			Str("url", request.URL.String()).
+			Int("attempt", attempt).
+			Msg("Retrying Tendermint RPC request")
+
+		response, err = t.next.RoundTrip(request)
+	}
+
+	return response, err
+}
+
+// isEndpointFailure returns whether the other endpoints should be tried instead. Unlike with the retries,
+// a 500 from one node can be a success on another one, like if the first one has pruned the queried height.
+func isEndpointFailure(response *http.Response, err error) bool {
+	return err != nil || response.StatusCode >= http.StatusInternalServerError
+}
+
+// isRetryable returns whether the request failed because of the network or a gateway in front
+// of the node. Other server errors, like 500, are returned by the node itself and would
+// most likely be returned again.
+func isRetryable(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// getRecentProposers returns the proposers of the last count blocks, or less, if the chain is younger.
+// The /blockchain pages are fetched concurrently, at most --max-concurrency at once.
+func getRecentProposers(ctx context.Context, count int64) ([]sdk.ConsAddress, error) {
+	client, err := newTendermintClient()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	latestHeight := status.SyncInfo.LatestBlockHeight
+	lowestHeight := latestHeight - count + 1
+	if lowestHeight < 1 {
+		lowestHeight = 1
+	}
+
+	var pagesMaxHeights []int64
+	for maxHeight := latestHeight; maxHeight >= lowestHeight; maxHeight -= blockchainInfoMaxBlocks {
+		pagesMaxHeights = append(pagesMaxHeights, maxHeight)
+	}
+
+	pages := make([][]sdk.ConsAddress, len(pagesMaxHeights))
+	errs := make([]error, len(pagesMaxHeights))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, MaxConcurrency)
+
+	for index, maxHeight := range pagesMaxHeights {
+		wg.Add(1)
+		go func(index int, maxHeight int64) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			minHeight := maxHeight - blockchainInfoMaxBlocks + 1
+			if minHeight < lowestHeight {
+				minHeight = lowestHeight
+			}
+
+			response, err := client.BlockchainInfo(ctx, minHeight, maxHeight)
+			if err != nil {
+				errs[index] = err
+				return
+			}
+
+			for _, blockMeta := range response.BlockMetas {
+				pages[index] = append(pages[index], sdk.ConsAddress(blockMeta.Header.ProposerAddress))
+			}
+		}(index, maxHeight)
+	}
+
+	wg.Wait()
+
+	proposers := make([]sdk.ConsAddress, 0, count)
+	for index, page := range pages {
+		if errs[index] != nil {
+			return nil, errs[index]
+		}
+
+		proposers = append(proposers, page...)
+	}
+
+	return proposers, nil
+}
+
+// getBlockTimes returns the average time between the latest blocks and the time between
+// the two most recent ones. It only takes a single /blockchain page, which is enough
+// to smooth out the occasional slow block for the average.
+func getBlockTimes(ctx context.Context) (time.Duration, time.Duration, error) {
+	client, err := newTendermintClient()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// with both heights set to 0 Tendermint returns the latest blocks, newest first
+	response, err := client.BlockchainInfo(ctx, 0, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(response.BlockMetas) < 2 {
+		return 0, 0, fmt.Errorf("need at least 2 blocks to calculate block time, got %d", len(response.BlockMetas))
+	}
+
+	newest := response.BlockMetas[0].Header.Time
+	previous := response.BlockMetas[1].Header.Time
+	oldest := response.BlockMetas[len(response.BlockMetas)-1].Header.Time
+
+	average := newest.Sub(oldest) / time.Duration(len(response.BlockMetas)-1)
+	return average, newest.Sub(previous), nil
+}
@@ -2,54 +2,155 @@ package main
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net/http"
-	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/cosmos/cosmos-sdk/simapp"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	distributiontypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
 	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	tmtypes "github.com/tendermint/tendermint/types"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// the details can be a few paragraphs long, which is too much for a label value
+const validatorDetailsMaxLength = 140
+
 func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
 	requestStart := time.Now()
-	sublogger := log.With().
-		Str("request-id", uuid.New().String()).
-		Logger()
+	sublogger := zerolog.Ctx(r.Context())
 
-	address := r.URL.Query().Get("address")
-	myAddress, err := sdk.ValAddressFromBech32(address)
-	if err != nil {
-		sublogger.Error().
-			Str("address", address).
-			Err(err).
-			Msg("Could not get address")
+	ctx := r.Context()
+
+	addressParam := r.URL.Query().Get("address")
+	monikerParam := r.URL.Query().Get("moniker")
+
+	if addressParam != "" && monikerParam != "" {
+		sublogger.Error().Msg("Both address and moniker are provided")
+		http.Error(w, "Only one of address and moniker should be provided", http.StatusBadRequest)
 		return
 	}
 
+	if monikerParam != "" {
+		address, err := getValidatorAddressByMoniker(ctx, grpcConn, monikerParam)
+		if err != nil {
+			sublogger.Error().
+				Str("moniker", monikerParam).
+				Err(err).
+				Msg("Could not get validator by moniker")
+
+			switch {
+			case errors.Is(err, errValidatorNotFound):
+				http.Error(w, "Could not find validator with moniker "+monikerParam, http.StatusNotFound)
+			case errors.Is(err, errValidatorAmbiguous):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Could not get validators", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		addressParam = address
+	}
+
+	// the default validators come from the config and not from the request,
+	// so they are not limited by --max-validators-per-request
+	var addresses []string
+	if addressParam != "" {
+		addresses = strings.Split(addressParam, ",")
+		if len(addresses) > MaxValidatorsPerRequest {
+			sublogger.Error().
+				Int("addresses", len(addresses)).
+				Int("limit", MaxValidatorsPerRequest).
+				Msg("Too many validators requested")
+			http.Error(w, "Too many validators requested", http.StatusBadRequest)
+			return
+		}
+	} else {
+		addresses = getDefaultValidatorAddresses()
+	}
+
+	if len(addresses) == 0 {
+		sublogger.Error().Msg("Address is not provided and neither --default-validator nor --default-to-watched is set")
+		http.Error(w, "Address is not provided", http.StatusBadRequest)
+		return
+	}
+
+	registry := getValidatorRegistry(ctx, grpcConn, addresses)
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/validator?address="+strings.Join(addresses, ",")).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
+
+// getDefaultValidatorAddresses returns the validators to query if none is passed:
+// --default-validator, or the watched ones if --default-to-watched is set.
+func getDefaultValidatorAddresses() []string {
+	if DefaultValidator != "" {
+		return strings.Split(DefaultValidator, ",")
+	}
+
+	if DefaultToWatched {
+		// so the endpoint can be scraped by a static job without any relabeling
+		return WatchedValidators
+	}
+
+	return nil
+}
+
+// getValidatorRegistry queries the validators metrics and returns the registry with them,
+// so they can be served both on /metrics/validator and on /metrics.
+func getValidatorRegistry(ctx context.Context, grpcConn *grpc.ClientConn, addresses []string) *prometheus.Registry {
+	sublogger := zerolog.Ctx(ctx)
+
 	validatorDelegationsGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_delegations",
 			Help:        "Delegations of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom", "delegated_by"},
 	)
 
+	validatorSelfDelegatedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_self_delegated",
+			Help:        "Tokens delegated to the Cosmos-based blockchain validator by its own account",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "denom"},
+	)
+
 	validatorTokensGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_tokens",
 			Help:        "Tokens of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom"},
 	)
@@ -58,16 +159,41 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_delegators_shares",
 			Help:        "Delegators shares of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom"},
 	)
 
+	validatorInfoGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_info",
+			Help:        "Description of the Cosmos-based blockchain validator, value is always 1",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "identity", "website", "security_contact", "details"},
+	)
+
 	validatorCommissionRateGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_commission_rate",
 			Help:        "Commission rate of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+	validatorCommissionMaxRateGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_commission_max_rate",
+			Help:        "Max commission rate the Cosmos-based blockchain validator can ever set",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+	validatorCommissionMaxChangeRateGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_commission_max_change_rate",
+			Help:        "Max daily commission rate change of the Cosmos-based blockchain validator",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -75,7 +201,16 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_commission",
 			Help:        "Commission of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "denom"},
+	)
+
+	validatorCommissionUSDGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_commission_usd",
+			Help:        "Commission of the Cosmos-based blockchain validator in --denom, in USD",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom"},
 	)
@@ -84,7 +219,7 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_rewards",
 			Help:        "Rewards of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom"},
 	)
@@ -93,7 +228,7 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_unbondings",
 			Help:        "Unbondings of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom", "unbonded_by"},
 	)
@@ -102,7 +237,7 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_redelegations",
 			Help:        "Redelegations of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom", "redelegated_by", "redelegated_to"},
 	)
@@ -111,16 +246,61 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_missed_blocks",
 			Help:        "Missed blocks of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorUptimePercentGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_uptime_percent",
+			Help:        "Percent of the blocks signed by the Cosmos-based blockchain validator in the signed blocks window",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
 
+	validatorIndexOffsetGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_index_offset",
+			Help:        "Index offset of the Cosmos-based blockchain validator in the signed blocks window",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "valcons"},
+	)
+
+	validatorStartHeightGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_start_height",
+			Help:        "Height at which the Cosmos-based blockchain validator became bonded",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "valcons"},
+	)
+
+	validatorJailedUntilGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_jailed_until",
+			Help:        "Unix timestamp until which the Cosmos-based blockchain validator is jailed, 0 if it was never jailed",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "valcons"},
+	)
+
+	validatorTombstonedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_tombstoned",
+			Help:        "1 if the Cosmos-based blockchain validator is tombstoned, 0 if no",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "valcons"},
+	)
+
 	validatorRankGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_rank",
-			Help:        "Rank of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			Help:        "Rank of the bonded Cosmos-based blockchain validator by tokens, starting from 1",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -129,7 +309,7 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_active",
 			Help:        "1 if the Cosmos-based blockchain validator is in active set, 0 if no",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -137,8 +317,8 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 	validatorStatusGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_status",
-			Help:        "Status of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			Help:        "Bond status of the Cosmos-based blockchain validator: 0 is unspecified, 1 is unbonded, 2 is unbonding, 3 is bonded",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -147,515 +327,966 @@ func ValidatorHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cli
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validator_jailed",
 			Help:        "1 if the Cosmos-based blockchain validator is jailed, 0 if no",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorRewardShareGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_reward_share",
+			Help:        "Share of the Cosmos-based blockchain validator outstanding rewards in all the undistributed rewards (outstanding rewards and community pool)",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "denom"},
+	)
+
+	validatorMissedBlocksRateGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_missed_blocks_rate",
+			Help:        "Approximate rate of missed blocks of the Cosmos-based blockchain validator since the previous scrape, per second",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorInConsensusSetGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_in_consensus_set",
+			Help:        "1 if the Cosmos-based blockchain validator is in the Tendermint validator set, 0 if no",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorBondedTokensReferenceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_bonded_tokens_reference",
+			Help:        "Tokens of the Cosmos-based blockchain validator, converted to the reference denom with --reference-rate",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker", "denom"},
+	)
+
+	validatorUnvotedProposalsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_unvoted_proposals",
+			Help:        "Amount of proposals in the voting period the Cosmos-based blockchain validator hasn't voted on",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorProposerShareGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_proposer_share",
+			Help:        "Share of the last --proposer-sample-size blocks proposed by the Cosmos-based blockchain validator",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorVotingPowerShareGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_voting_power_share",
+			Help:        "Share of the total voting power the Cosmos-based blockchain validator has",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
 
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(validatorDelegationsGauge)
+	registry.MustRegister(validatorSelfDelegatedGauge)
 	registry.MustRegister(validatorTokensGauge)
 	registry.MustRegister(validatorDelegatorSharesGauge)
+	registry.MustRegister(validatorInfoGauge)
 	registry.MustRegister(validatorCommissionRateGauge)
+	registry.MustRegister(validatorCommissionMaxRateGauge)
+	registry.MustRegister(validatorCommissionMaxChangeRateGauge)
 	registry.MustRegister(validatorCommissionGauge)
 	registry.MustRegister(validatorRewardsGauge)
 	registry.MustRegister(validatorUnbondingsGauge)
 	registry.MustRegister(validatorRedelegationsGauge)
 	registry.MustRegister(validatorMissedBlocksGauge)
+	registry.MustRegister(validatorUptimePercentGauge)
+	registry.MustRegister(validatorIndexOffsetGauge)
+	registry.MustRegister(validatorStartHeightGauge)
+	registry.MustRegister(validatorJailedUntilGauge)
+	registry.MustRegister(validatorTombstonedGauge)
 	registry.MustRegister(validatorRankGauge)
 	registry.MustRegister(validatorIsActiveGauge)
 	registry.MustRegister(validatorStatusGauge)
 	registry.MustRegister(validatorJailedGauge)
+	registry.MustRegister(validatorRewardShareGauge)
+	registry.MustRegister(validatorMissedBlocksRateGauge)
+	registry.MustRegister(validatorInConsensusSetGauge)
+	registry.MustRegister(validatorBondedTokensReferenceGauge)
+	registry.MustRegister(validatorUnvotedProposalsGauge)
+	registry.MustRegister(validatorVotingPowerShareGauge)
+	if ProposerSampleSize > 0 {
+		registry.MustRegister(validatorProposerShareGauge)
+	}
+	if PriceSource != "" {
+		registry.MustRegister(validatorCommissionUSDGauge)
+	}
 
-	// doing this not in goroutine as we'll need the moniker value later
-	sublogger.Debug().
-		Str("address", address).
-		Msg("Started querying validator")
-	validatorQueryStart := time.Now()
+	// the blocks are the same for all the validators, so they are only sampled once per request
+	var proposers []sdk.ConsAddress
+	if ProposerSampleSize > 0 {
+		sublogger.Debug().Msg("Started querying recent blocks proposers")
+		queryStart := time.Now()
 
-	stakingClient := stakingtypes.NewQueryClient(grpcConn)
-	validator, err := stakingClient.Validator(
-		context.Background(),
-		&stakingtypes.QueryValidatorRequest{ValidatorAddr: myAddress.String()},
-	)
-	if err != nil {
-		sublogger.Error().
-			Str("address", address).
-			Err(err).
-			Msg("Could not get validator")
-		return
+		var err error
+		proposers, err = getRecentProposers(ctx, ProposerSampleSize)
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Could not get recent blocks proposers")
+		} else {
+			sublogger.Debug().
+				Int("blocks", len(proposers)).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying recent blocks proposers")
+		}
 	}
 
-	sublogger.Debug().
-		Str("address", address).
-		Float64("request-time", time.Since(validatorQueryStart).Seconds()).
-		Msg("Finished querying validator")
+	// the signed blocks window is the same for all the validators, so it's only queried once per request
+	var signedBlocksWindow int64
+	sublogger.Debug().Msg("Started querying slashing params")
+	queryStart := time.Now()
 
-	if value, err := strconv.ParseFloat(validator.Validator.Tokens.String(), 64); err != nil {
-		sublogger.Error().
-			Str("address", address).
-			Err(err).
-			Msg("Could not parse validator tokens")
+	slashingClient := slashingtypes.NewQueryClient(grpcConn)
+	slashingParamsRes, err := slashingClient.Params(ctx, &slashingtypes.QueryParamsRequest{})
+	if err != nil {
+		sublogger.Error().Err(err).Msg("Could not get slashing params")
 	} else {
-		validatorTokensGauge.With(prometheus.Labels{
-			"address": validator.Validator.OperatorAddress,
-			"moniker": validator.Validator.Description.Moniker,
-			"denom":   Denom,
-		}).Set(value / DenomCoefficient)
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying slashing params")
+		signedBlocksWindow = slashingParamsRes.Params.SignedBlocksWindow
 	}
 
-	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-	if value, err := strconv.ParseFloat(validator.Validator.DelegatorShares.String(), 64); err != nil {
-		sublogger.Error().
-			Str("address", address).
-			Err(err).
-			Msg("Could not parse delegator shares")
+	// the validators set, staking params and Tendermint validators are the same for all the validators
+	// too, so they're only queried once per request instead of for every validator
+	sublogger.Debug().Msg("Started querying validators")
+	queryStart = time.Now()
+
+	var bondedRanks map[string]int
+	validators, validatorsErr := queryValidators(ctx, grpcConn)
+	if validatorsErr != nil {
+		sublogger.Error().Err(validatorsErr).Msg("Could not get validators")
 	} else {
-		validatorDelegatorSharesGauge.With(prometheus.Labels{
-			"address": validator.Validator.OperatorAddress,
-			"moniker": validator.Validator.Description.Moniker,
-			"denom":   Denom,
-		}).Set(value / DenomCoefficient)
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validators")
+		bondedRanks = getBondedRanks(validators)
 	}
 
-	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-	if rate, err := strconv.ParseFloat(validator.Validator.Commission.CommissionRates.Rate.String(), 64); err != nil {
-		sublogger.Error().
-			Str("address", address).
-			Err(err).
-			Msg("Could not parse commission rate")
+	sublogger.Debug().Msg("Started querying staking params")
+	queryStart = time.Now()
+
+	activeValidators := map[string]bool{}
+	stakingParamsRes, stakingParamsErr := stakingtypes.NewQueryClient(grpcConn).Params(
+		ctx,
+		&stakingtypes.QueryParamsRequest{},
+	)
+	if stakingParamsErr != nil {
+		sublogger.Error().Err(stakingParamsErr).Msg("Could not get staking params")
 	} else {
-		validatorCommissionRateGauge.With(prometheus.Labels{
-			"address": validator.Validator.OperatorAddress,
-			"moniker": validator.Validator.Description.Moniker,
-		}).Set(rate)
-	}
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying staking params")
 
-	validatorStatusGauge.With(prometheus.Labels{
-		"address": validator.Validator.OperatorAddress,
-		"moniker": validator.Validator.Description.Moniker,
-	}).Set(float64(validator.Validator.Status))
+		for _, activeValidator := range getActiveSet(validators, stakingParamsRes.Params.MaxValidators) {
+			activeValidators[activeValidator.OperatorAddress] = true
+		}
+	}
 
-	// golang doesn't have a ternary operator, so we have to stick with this ugly solution
-	var jailed float64
+	sublogger.Debug().Msg("Started querying Tendermint validators")
+	queryStart = time.Now()
 
-	if validator.Validator.Jailed {
-		jailed = 1
+	tendermintValidators, tendermintValidatorsErr := getTendermintValidators(ctx)
+	if tendermintValidatorsErr != nil {
+		sublogger.Error().Err(tendermintValidatorsErr).Msg("Could not get Tendermint validators")
 	} else {
-		jailed = 0
+		sublogger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying Tendermint validators")
 	}
-	validatorJailedGauge.With(prometheus.Labels{
-		"address": validator.Validator.OperatorAddress,
-		"moniker": validator.Validator.Description.Moniker,
-	}).Set(jailed)
 
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	collectValidatorMetrics := func(address string) {
+		myAddress, err := sdk.ValAddressFromBech32(address)
+		if err != nil {
+			sublogger.Error().
+				Str("address", address).
+				Err(err).
+				Msg("Could not get address")
+			return
+		}
 
+		// doing this not in goroutine as we'll need the moniker value later
 		sublogger.Debug().
 			Str("address", address).
-			Msg("Started querying validator delegations")
-		queryStart := time.Now()
+			Msg("Started querying validator")
+		validatorQueryStart := time.Now()
 
 		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		stakingRes, err := stakingClient.ValidatorDelegations(
-			context.Background(),
-			&stakingtypes.QueryValidatorDelegationsRequest{ValidatorAddr: myAddress.String()},
+		validator, err := stakingClient.Validator(
+			ctx,
+			&stakingtypes.QueryValidatorRequest{ValidatorAddr: myAddress.String()},
 		)
 		if err != nil {
 			sublogger.Error().
 				Str("address", address).
 				Err(err).
-				Msg("Could not get validator delegations")
+				Msg("Could not get validator")
 			return
 		}
 
 		sublogger.Debug().
 			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator delegations")
+			Float64("request-time", time.Since(validatorQueryStart).Seconds()).
+			Msg("Finished querying validator")
 
-		for _, delegation := range stakingRes.DelegationResponses {
-			value, err := strconv.ParseFloat(delegation.Balance.Amount.String(), 64)
-			if err != nil {
-				log.Error().
-					Err(err).
-					Str("address", address).
-					Msg("Could not convert delegation entry")
-			} else {
-				validatorDelegationsGauge.With(prometheus.Labels{
-					"moniker":      validator.Validator.Description.Moniker,
-					"address":      delegation.Delegation.ValidatorAddress,
-					"denom":        Denom,
-					"delegated_by": delegation.Delegation.DelegatorAddress,
-				}).Set(value / DenomCoefficient)
+		if value, err := strconv.ParseFloat(validator.Validator.Tokens.String(), 64); err != nil {
+			sublogger.Error().
+				Str("address", address).
+				Err(err).
+				Msg("Could not parse validator tokens")
+		} else {
+			validatorTokensGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+				"denom":   Denom,
+			}).Set(value / DenomCoefficient)
+
+			if ReferenceDenom != "" {
+				validatorBondedTokensReferenceGauge.With(prometheus.Labels{
+					"address": validator.Validator.OperatorAddress,
+					"moniker": validator.Validator.Description.Moniker,
+					"denom":   ReferenceDenom,
+				}).Set(value / DenomCoefficient * ReferenceRate)
 			}
 		}
-	}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+		// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+		if value, err := strconv.ParseFloat(validator.Validator.DelegatorShares.String(), 64); err != nil {
+			sublogger.Error().
+				Str("address", address).
+				Err(err).
+				Msg("Could not parse delegator shares")
+		} else {
+			validatorDelegatorSharesGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+				"denom":   Denom,
+			}).Set(value / DenomCoefficient)
+		}
 
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying validator commission")
-		queryStart := time.Now()
+		description := validator.Validator.Description
+		validatorInfoGauge.With(prometheus.Labels{
+			"address":          validator.Validator.OperatorAddress,
+			"moniker":          description.Moniker,
+			"identity":         description.Identity,
+			"website":          description.Website,
+			"security_contact": description.SecurityContact,
+			"details":          truncateLabelValue(description.Details, validatorDetailsMaxLength),
+		}).Set(1)
+
+		// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+		if rate, err := strconv.ParseFloat(validator.Validator.Commission.CommissionRates.Rate.String(), 64); err != nil {
+			sublogger.Error().
+				Str("address", address).
+				Err(err).
+				Msg("Could not parse commission rate")
+		} else {
+			validatorCommissionRateGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+			}).Set(rate)
+		}
 
-		distributionClient := distributiontypes.NewQueryClient(grpcConn)
-		distributionRes, err := distributionClient.ValidatorCommission(
-			context.Background(),
-			&distributiontypes.QueryValidatorCommissionRequest{ValidatorAddress: myAddress.String()},
-		)
-		if err != nil {
+		if maxRate, err := strconv.ParseFloat(validator.Validator.Commission.CommissionRates.MaxRate.String(), 64); err != nil {
 			sublogger.Error().
 				Str("address", address).
 				Err(err).
-				Msg("Could not get validator commission")
-			return
+				Msg("Could not parse commission max rate")
+		} else {
+			validatorCommissionMaxRateGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+			}).Set(maxRate)
 		}
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator commission")
+		if maxChangeRate, err := strconv.ParseFloat(validator.Validator.Commission.CommissionRates.MaxChangeRate.String(), 64); err != nil {
+			sublogger.Error().
+				Str("address", address).
+				Err(err).
+				Msg("Could not parse commission max change rate")
+		} else {
+			validatorCommissionMaxChangeRateGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+			}).Set(maxChangeRate)
+		}
 
-		for _, commission := range distributionRes.Commission.Commission {
-			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-			value, err := strconv.ParseFloat(commission.Amount.String(), 64)
+		validatorStatusGauge.With(prometheus.Labels{
+			"address": validator.Validator.OperatorAddress,
+			"moniker": validator.Validator.Description.Moniker,
+		}).Set(float64(validator.Validator.Status))
+
+		// golang doesn't have a ternary operator, so we have to stick with this ugly solution
+		var jailed float64
+
+		if validator.Validator.Jailed {
+			jailed = 1
+		} else {
+			jailed = 0
+		}
+		validatorJailedGauge.With(prometheus.Labels{
+			"address": validator.Validator.OperatorAddress,
+			"moniker": validator.Validator.Description.Moniker,
+		}).Set(jailed)
+
+		// doing this not in goroutine as both signing info and consensus set queries need it
+		encCfg := simapp.MakeTestEncodingConfig()
+		interfaceRegistry := encCfg.InterfaceRegistry
+
+		consAddress, consAddressErr := getValidatorConsAddress(validator.Validator, interfaceRegistry)
+		if consAddressErr != nil {
+			sublogger.Error().
+				Str("address", address).
+				Err(consAddressErr).
+				Msg("Could not get validator consensus address, skipping signing info and consensus set metrics")
+		}
+
+		if consAddressErr == nil && len(proposers) > 0 {
+			proposed := 0
+			for _, proposer := range proposers {
+				if consAddress.Equals(proposer) {
+					proposed++
+				}
+			}
+
+			validatorProposerShareGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+			}).Set(float64(proposed) / float64(len(proposers)))
+		}
+
+		// outstanding rewards of this validator and of the whole network, by denom,
+		// used to calculate the validator rewards share
+		validatorRewards := map[string]float64{}
+		networkRewards := map[string]float64{}
+
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying validator delegations")
+			queryStart := time.Now()
+
+			delegations, err := queryValidatorDelegations(ctx, grpcConn, myAddress.String())
 			if err != nil {
-				log.Error().
-					Err(err).
+				sublogger.Error().
 					Str("address", address).
-					Msg("Could not get validator commission")
-			} else {
-				validatorCommissionGauge.With(prometheus.Labels{
-					"address": address,
-					"moniker": validator.Validator.Description.Moniker,
-					"denom":   Denom,
-				}).Set(value / DenomCoefficient)
+					Err(err).
+					Msg("Could not get validator delegations")
+				return
 			}
-		}
-	}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying validator delegations")
 
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying validator rewards")
-		queryStart := time.Now()
+			for _, delegation := range delegations {
+				value, err := strconv.ParseFloat(delegation.Balance.Amount.String(), 64)
+				if err != nil {
+					sublogger.Error().
+						Err(err).
+						Str("address", address).
+						Msg("Could not convert delegation entry")
+				} else {
+					denom, amount := convertCoin(delegation.Balance.Denom, value)
+					validatorDelegationsGauge.With(prometheus.Labels{
+						"moniker":      validator.Validator.Description.Moniker,
+						"address":      delegation.Delegation.ValidatorAddress,
+						"denom":        denom,
+						"delegated_by": delegation.Delegation.DelegatorAddress,
+					}).Set(amount)
+				}
+			}
+		}()
 
-		distributionClient := distributiontypes.NewQueryClient(grpcConn)
-		distributionRes, err := distributionClient.ValidatorOutstandingRewards(
-			context.Background(),
-			&distributiontypes.QueryValidatorOutstandingRewardsRequest{ValidatorAddress: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get validator rewards")
-			return
-		}
+				Msg("Started querying validator self-delegation")
+			queryStart := time.Now()
+
+			// the self-delegation is made from the account with the same bytes as the operator address
+			stakingClient := stakingtypes.NewQueryClient(grpcConn)
+			stakingRes, err := stakingClient.Delegation(
+				ctx,
+				&stakingtypes.QueryDelegationRequest{
+					DelegatorAddr: sdk.AccAddress(myAddress).String(),
+					ValidatorAddr: myAddress.String(),
+				},
+			)
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator rewards")
+			selfDelegatedLabels := prometheus.Labels{
+				"address": address,
+				"moniker": validator.Validator.Description.Moniker,
+				"denom":   Denom,
+			}
+
+			// the validator has unbonded all of its self-delegation
+			if status.Code(err) == codes.NotFound {
+				validatorSelfDelegatedGauge.With(selfDelegatedLabels).Set(0)
+				return
+			}
+
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get validator self-delegation")
+				return
+			}
+
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying validator self-delegation")
 
-		for _, reward := range distributionRes.Rewards.Rewards {
 			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-			if value, err := strconv.ParseFloat(reward.Amount.String(), 64); err != nil {
+			if value, err := strconv.ParseFloat(stakingRes.DelegationResponse.Balance.Amount.String(), 64); err != nil {
 				sublogger.Error().
 					Str("address", address).
 					Err(err).
-					Msg("Could not get reward")
+					Msg("Could not parse self-delegation")
 			} else {
-				validatorRewardsGauge.With(prometheus.Labels{
-					"address": address,
-					"moniker": validator.Validator.Description.Moniker,
-					"denom":   Denom,
-				}).Set(value / DenomCoefficient)
+				validatorSelfDelegatedGauge.With(selfDelegatedLabels).Set(value / DenomCoefficient)
 			}
-		}
-	}()
+		}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying validator unbonding delegations")
-		queryStart := time.Now()
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying validator commission")
+			queryStart := time.Now()
+
+			distributionClient := distributiontypes.NewQueryClient(grpcConn)
+			distributionRes, err := distributionClient.ValidatorCommission(
+				ctx,
+				&distributiontypes.QueryValidatorCommissionRequest{ValidatorAddress: myAddress.String()},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get validator commission")
+				return
+			}
 
-		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		stakingRes, err := stakingClient.ValidatorUnbondingDelegations(
-			context.Background(),
-			&stakingtypes.QueryValidatorUnbondingDelegationsRequest{ValidatorAddr: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get validator unbonding delegations")
-			return
-		}
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying validator commission")
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator unbonding delegations")
+			for _, commission := range distributionRes.Commission.Commission {
+				if !isRewardsDenomAllowed(commission.Denom) {
+					continue
+				}
 
-		for _, unbonding := range stakingRes.UnbondingResponses {
-			var sum float64 = 0
-			for _, entry := range unbonding.Entries {
-				value, err := strconv.ParseFloat(entry.Balance.String(), 64)
+				// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+				value, err := strconv.ParseFloat(commission.Amount.String(), 64)
 				if err != nil {
-					log.Error().
+					sublogger.Error().
 						Err(err).
 						Str("address", address).
-						Msg("Could not convert unbonding delegation entry")
+						Msg("Could not get validator commission")
 				} else {
-					sum += value
+					denom, amount := convertCoin(commission.Denom, value)
+					validatorCommissionGauge.With(prometheus.Labels{
+						"address": address,
+						"moniker": validator.Validator.Description.Moniker,
+						"denom":   denom,
+					}).Set(amount)
+
+					if usdValue, ok := getUSDValue(denom, amount); ok {
+						validatorCommissionUSDGauge.With(prometheus.Labels{
+							"address": address,
+							"moniker": validator.Validator.Description.Moniker,
+							"denom":   denom,
+						}).Set(usdValue)
+					}
 				}
 			}
+		}()
 
-			validatorUnbondingsGauge.With(prometheus.Labels{
-				"address":     unbonding.ValidatorAddress,
-				"moniker":     validator.Validator.Description.Moniker,
-				"denom":       Denom, // unbonding does not have denom in response for some reason
-				"unbonded_by": unbonding.DelegatorAddress,
-			}).Set(sum / DenomCoefficient)
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying validator redelegations")
-		queryStart := time.Now()
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying validator rewards")
+			queryStart := time.Now()
+
+			distributionClient := distributiontypes.NewQueryClient(grpcConn)
+			distributionRes, err := distributionClient.ValidatorOutstandingRewards(
+				ctx,
+				&distributiontypes.QueryValidatorOutstandingRewardsRequest{ValidatorAddress: myAddress.String()},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get validator rewards")
+				return
+			}
 
-		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		stakingRes, err := stakingClient.Redelegations(
-			context.Background(),
-			&stakingtypes.QueryRedelegationsRequest{SrcValidatorAddr: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get redelegations")
-			return
-		}
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying validator rewards")
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator redelegations")
+			for _, reward := range distributionRes.Rewards.Rewards {
+				if !isRewardsDenomAllowed(reward.Denom) {
+					continue
+				}
 
-		for _, redelegation := range stakingRes.RedelegationResponses {
-			var sum float64 = 0
-			for _, entry := range redelegation.Entries {
-				value, err := strconv.ParseFloat(entry.Balance.String(), 64)
-				if err != nil {
-					log.Error().
+				// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+				if value, err := strconv.ParseFloat(reward.Amount.String(), 64); err != nil {
+					sublogger.Error().
+						Str("address", address).
 						Err(err).
+						Msg("Could not get reward")
+				} else {
+					denom, amount := convertCoin(reward.Denom, value)
+					validatorRewardsGauge.With(prometheus.Labels{
+						"address": address,
+						"moniker": validator.Validator.Description.Moniker,
+						"denom":   denom,
+					}).Set(amount)
+					validatorRewards[reward.Denom] += value
+				}
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying distribution module balance")
+			queryStart := time.Now()
+
+			// distribution module account holds all the rewards that were not withdrawn yet
+			// and the community pool, so its balance is the total we're calculating the share of
+			bankClient := banktypes.NewQueryClient(grpcConn)
+			bankRes, err := bankClient.AllBalances(
+				ctx,
+				&banktypes.QueryAllBalancesRequest{
+					Address: authtypes.NewModuleAddress(distributiontypes.ModuleName).String(),
+				},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get distribution module balance")
+				return
+			}
+
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying distribution module balance")
+
+			for _, balance := range bankRes.Balances {
+				// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+				if value, err := strconv.ParseFloat(balance.Amount.String(), 64); err != nil {
+					sublogger.Error().
 						Str("address", address).
-						Msg("Could not convert redelegation entry")
+						Err(err).
+						Msg("Could not parse distribution module balance")
 				} else {
-					sum += value
+					networkRewards[balance.Denom] = value
 				}
 			}
+		}()
 
-			validatorRedelegationsGauge.With(prometheus.Labels{
-				"address":        redelegation.Redelegation.ValidatorSrcAddress,
-				"moniker":        validator.Validator.Description.Moniker,
-				"denom":          Denom, // redelegation does not have denom in response for some reason
-				"redelegated_by": redelegation.Redelegation.DelegatorAddress,
-				"redelegated_to": redelegation.Redelegation.ValidatorDstAddress,
-			}).Set(sum / DenomCoefficient)
-		}
-	}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying validator unbonding delegations")
+			queryStart := time.Now()
 
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying validator signing info")
-		queryStart := time.Now()
+			unbondings, err := queryValidatorUnbondingDelegations(ctx, grpcConn, myAddress.String())
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get validator unbonding delegations")
+				return
+			}
 
-		encCfg := simapp.MakeTestEncodingConfig()
-		interfaceRegistry := encCfg.InterfaceRegistry
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying validator unbonding delegations")
+
+			for _, unbonding := range unbondings {
+				var sum float64 = 0
+				for _, entry := range unbonding.Entries {
+					value, err := strconv.ParseFloat(entry.Balance.String(), 64)
+					if err != nil {
+						sublogger.Error().
+							Err(err).
+							Str("address", address).
+							Msg("Could not convert unbonding delegation entry")
+					} else {
+						sum += value
+					}
+				}
 
-		err := validator.Validator.UnpackInterfaces(interfaceRegistry) // Unpack interfaces, to populate the Anys' cached values
-		if err != nil {
-			sublogger.Error().
+				validatorUnbondingsGauge.With(prometheus.Labels{
+					"address":     unbonding.ValidatorAddress,
+					"moniker":     validator.Validator.Description.Moniker,
+					"denom":       Denom, // unbonding does not have denom in response for some reason
+					"unbonded_by": unbonding.DelegatorAddress,
+				}).Set(sum / DenomCoefficient)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get unpack validator inferfaces")
-		}
+				Msg("Started querying validator redelegations")
+			queryStart := time.Now()
+
+			redelegations, err := queryRedelegations(
+				ctx,
+				grpcConn,
+				stakingtypes.QueryRedelegationsRequest{SrcValidatorAddr: myAddress.String()},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get redelegations")
+				return
+			}
 
-		pubKey, err := validator.Validator.GetConsAddr()
-		if err != nil {
-			sublogger.Error().
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get validator pubkey")
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying validator redelegations")
+
+			for _, redelegation := range redelegations {
+				var sum float64 = 0
+				for _, entry := range redelegation.Entries {
+					value, err := strconv.ParseFloat(entry.Balance.String(), 64)
+					if err != nil {
+						sublogger.Error().
+							Err(err).
+							Str("address", address).
+							Msg("Could not convert redelegation entry")
+					} else {
+						sum += value
+					}
+				}
+
+				validatorRedelegationsGauge.With(prometheus.Labels{
+					"address":        redelegation.Redelegation.ValidatorSrcAddress,
+					"moniker":        validator.Validator.Description.Moniker,
+					"denom":          Denom, // redelegation does not have denom in response for some reason
+					"redelegated_by": redelegation.Redelegation.DelegatorAddress,
+					"redelegated_to": redelegation.Redelegation.ValidatorDstAddress,
+				}).Set(sum / DenomCoefficient)
+			}
+		}()
+
+		if consAddressErr == nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				sublogger.Debug().
+					Str("address", address).
+					Msg("Started querying validator signing info")
+				queryStart := time.Now()
+
+				slashingClient := slashingtypes.NewQueryClient(grpcConn)
+				slashingRes, err := slashingClient.SigningInfo(
+					ctx,
+					&slashingtypes.QuerySigningInfoRequest{ConsAddress: consAddress.String()},
+				)
+				if err != nil {
+					sublogger.Error().
+						Str("address", address).
+						Err(err).
+						Msg("Could not get validator signing info")
+					return
+				}
+
+				sublogger.Debug().
+					Str("address", address).
+					Float64("request-time", time.Since(queryStart).Seconds()).
+					Msg("Finished querying validator signing info")
+
+				sublogger.Debug().
+					Str("address", address).
+					Int64("missedBlocks", slashingRes.ValSigningInfo.MissedBlocksCounter).
+					Msg("Finished querying validator signing info")
+
+				validatorMissedBlocksGauge.With(prometheus.Labels{
+					"moniker": validator.Validator.Description.Moniker,
+					"address": address,
+				}).Set(float64(slashingRes.ValSigningInfo.MissedBlocksCounter))
+
+				if signedBlocksWindow > 0 {
+					signedBlocks := signedBlocksWindow - slashingRes.ValSigningInfo.MissedBlocksCounter
+					validatorUptimePercentGauge.With(prometheus.Labels{
+						"moniker": validator.Validator.Description.Moniker,
+						"address": address,
+					}).Set(float64(signedBlocks) / float64(signedBlocksWindow) * 100)
+				}
+
+				signingInfoLabels := prometheus.Labels{
+					"moniker": validator.Validator.Description.Moniker,
+					"address": address,
+					"valcons": consAddress.String(),
+				}
+
+				validatorIndexOffsetGauge.With(signingInfoLabels).Set(float64(slashingRes.ValSigningInfo.IndexOffset))
+				validatorStartHeightGauge.With(signingInfoLabels).Set(float64(slashingRes.ValSigningInfo.StartHeight))
+
+				// JailedUntil is the zero time (year 1) if the validator was never jailed,
+				// which would be a huge negative timestamp
+				jailedUntil := slashingRes.ValSigningInfo.JailedUntil
+				if jailedUntil.Unix() > 0 {
+					validatorJailedUntilGauge.With(signingInfoLabels).Set(float64(jailedUntil.Unix()))
+				} else {
+					validatorJailedUntilGauge.With(signingInfoLabels).Set(0)
+				}
+
+				if slashingRes.ValSigningInfo.Tombstoned {
+					validatorTombstonedGauge.With(signingInfoLabels).Set(1)
+				} else {
+					validatorTombstonedGauge.With(signingInfoLabels).Set(0)
+				}
+
+				missedBlocks := float64(slashingRes.ValSigningInfo.MissedBlocksCounter)
+				sampleKey := "validator_missed_blocks/" + address
+				now := time.Now()
+
+				if delta, elapsed, found := samples.Delta(sampleKey, missedBlocks, now); found && elapsed > 0 {
+					// missed blocks counter goes down when missed blocks are leaving the window,
+					// that's not something we want to report as a negative rate
+					rate := math.Max(delta, 0) / elapsed.Seconds()
+
+					validatorMissedBlocksRateGauge.With(prometheus.Labels{
+						"moniker": validator.Validator.Description.Moniker,
+						"address": address,
+					}).Set(samples.Smooth(sampleKey, rate))
+				}
+			}()
 		}
 
-		slashingClient := slashingtypes.NewQueryClient(grpcConn)
-		slashingRes, err := slashingClient.SigningInfo(
-			context.Background(),
-			&slashingtypes.QuerySigningInfoRequest{ConsAddress: pubKey.String()},
-		)
-		if err != nil {
-			sublogger.Error().
-				Str("address", address).
-				Err(err).
-				Msg("Could not get validator signing info")
-			return
+		if validatorsErr == nil {
+			// ranked the same way as on /metrics/validators, so the unbonded validators
+			// are not ranked and don't shift the numbering
+			if rank, found := bondedRanks[validator.Validator.OperatorAddress]; found {
+				validatorRankGauge.With(prometheus.Labels{
+					"moniker": validator.Validator.Description.Moniker,
+					"address": address,
+				}).Set(float64(rank))
+			} else {
+				sublogger.Debug().
+					Str("address", address).
+					Msg("Validator is not bonded, not reporting its rank")
+			}
 		}
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator signing info")
+		if validatorsErr == nil && stakingParamsErr == nil {
+			// golang doesn't have a ternary operator, so we have to stick with this ugly solution
+			var active float64
 
-		sublogger.Debug().
-			Str("address", address).
-			Int64("missedBlocks", slashingRes.ValSigningInfo.MissedBlocksCounter).
-			Msg("Finished querying validator signing info")
+			if activeValidators[validator.Validator.OperatorAddress] {
+				active = 1
+			} else {
+				active = 0
+			}
 
-		validatorMissedBlocksGauge.With(prometheus.Labels{
-			"moniker": validator.Validator.Description.Moniker,
-			"address": address,
-		}).Set(float64(slashingRes.ValSigningInfo.MissedBlocksCounter))
-	}()
+			validatorIsActiveGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+			}).Set(active)
+		}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+		if consAddressErr == nil && tendermintValidatorsErr == nil {
+			var inConsensusSet float64 = 0
 
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying validator other validators")
-		queryStart := time.Now()
+			var votingPower, totalVotingPower int64
 
-		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		stakingRes, err := stakingClient.Validators(
-			context.Background(),
-			&stakingtypes.QueryValidatorsRequest{
-				Pagination: &querytypes.PageRequest{
-					Limit: Limit,
-				},
-			},
-		)
-		if err != nil {
-			sublogger.Error().
-				Str("address", address).
-				Err(err).
-				Msg("Could not get other validators")
-			return
+			for _, tendermintValidator := range tendermintValidators {
+				totalVotingPower += tendermintValidator.VotingPower
+
+				if consAddress.Equals(sdk.ConsAddress(tendermintValidator.Address)) {
+					inConsensusSet = 1
+					votingPower = tendermintValidator.VotingPower
+				}
+			}
+
+			validatorInConsensusSetGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+			}).Set(inConsensusSet)
+
+			if totalVotingPower > 0 {
+				validatorVotingPowerShareGauge.With(prometheus.Labels{
+					"address": validator.Validator.OperatorAddress,
+					"moniker": validator.Validator.Description.Moniker,
+				}).Set(float64(votingPower) / float64(totalVotingPower))
+			}
 		}
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator other validators")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		validators := stakingRes.Validators
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying validator votes")
+			queryStart := time.Now()
+
+			govClient := govtypes.NewQueryClient(grpcConn)
+			proposalsResponse, err := govClient.Proposals(
+				ctx,
+				&govtypes.QueryProposalsRequest{
+					ProposalStatus: govtypes.StatusVotingPeriod,
+					Pagination: &querytypes.PageRequest{
+						Limit: Limit,
+					},
+				},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get voting period proposals")
+				return
+			}
 
-		// sorting by delegator shares to display rankings
-		sort.Slice(validators, func(i, j int) bool {
-			firstShares, firstErr := strconv.ParseFloat(validators[i].DelegatorShares.String(), 64)
-			secondShares, secondErr := strconv.ParseFloat(validators[j].DelegatorShares.String(), 64)
+			checkPossibleTruncation("voting_period_proposals", len(proposalsResponse.Proposals))
 
-			if firstErr != nil || secondErr != nil {
+			// validators vote from their self-delegation account, not the operator one
+			voter := sdk.AccAddress(myAddress).String()
+			unvoted, proposalID, err := countUnvotedProposals(ctx, govClient, proposalsResponse.Proposals, voter)
+			if err != nil {
 				sublogger.Error().
+					Str("address", address).
+					Uint64("proposal", proposalID).
 					Err(err).
-					Msg("Error converting delegator shares for sorting")
-				return true
+					Msg("Could not get validator vote")
+				return
 			}
 
-			return firstShares > secondShares
-		})
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying validator votes")
+
+			validatorUnvotedProposalsGauge.With(prometheus.Labels{
+				"address": validator.Validator.OperatorAddress,
+				"moniker": validator.Validator.Description.Moniker,
+			}).Set(float64(unvoted))
+		}()
 
-		var validatorRank int
+		wg.Wait()
 
-		for index, validatorIterated := range validators {
-			if validatorIterated.OperatorAddress == validator.Validator.OperatorAddress {
-				validatorRank = index + 1
-				break
+		for denom, rewards := range validatorRewards {
+			total, ok := networkRewards[denom]
+			if !ok || total == 0 {
+				continue
 			}
-		}
 
-		if validatorRank == 0 {
-			sublogger.Warn().
-				Str("address", address).
-				Msg("Could not find validator in validators list")
-			return
+			// the rewards were already converted when they were reported, so they aren't counted again
+			displayDenom, _ := convertCoinUncounted(denom, rewards)
+			validatorRewardShareGauge.With(prometheus.Labels{
+				"address": address,
+				"moniker": validator.Validator.Description.Moniker,
+				"denom":   displayDenom,
+			}).Set(rewards / total)
 		}
+	}
 
-		validatorRankGauge.With(prometheus.Labels{
-			"moniker": validator.Validator.Description.Moniker,
-			"address": address,
-		}).Set(float64(validatorRank))
+	var validatorsWg sync.WaitGroup
 
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying validator params")
-		queryStart = time.Now()
+	// each validator takes a dozen of queries, so with a lot of watched validators
+	// querying all of them at once could overwhelm the node
+	semaphore := make(chan struct{}, MaxConcurrency)
 
-		paramsRes, err := stakingClient.Params(
-			context.Background(),
-			&stakingtypes.QueryParamsRequest{},
-		)
-		if err != nil {
-			sublogger.Error().
-				Str("address", address).
-				Err(err).
-				Msg("Could not get params")
-			return
-		}
+	for _, address := range addresses {
+		validatorsWg.Add(1)
+		go func(address string) {
+			defer validatorsWg.Done()
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator params")
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
 
-		// golang doesn't have a ternary operator, so we have to stick with this ugly solution
-		var active float64
+			collectValidatorMetrics(address)
+		}(address)
+	}
 
-		if validatorRank <= int(paramsRes.Params.MaxValidators) {
-			active = 1
-		} else {
-			active = 0
-		}
+	validatorsWg.Wait()
 
-		validatorIsActiveGauge.With(prometheus.Labels{
-			"address": validator.Validator.OperatorAddress,
-			"moniker": validator.Validator.Description.Moniker,
-		}).Set(active)
-	}()
+	return registry
+}
 
-	wg.Wait()
+// getTendermintValidators returns the validators set at the latest height, as Tendermint sees it.
+func getTendermintValidators(ctx context.Context) ([]*tmtypes.Validator, error) {
+	client, err := newTendermintClient()
+	if err != nil {
+		return nil, err
+	}
 
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
-	sublogger.Info().
-		Str("method", "GET").
-		Str("endpoint", "/metrics/validator?address="+address).
-		Float64("request-time", time.Since(requestStart).Seconds()).
-		Msg("Request processed")
+	var validators []*tmtypes.Validator
+
+	page := 1
+	perPage := 100
+
+	for {
+		response, err := client.Validators(ctx, nil, &page, &perPage)
+		if err != nil {
+			return nil, err
+		}
+
+		validators = append(validators, response.Validators...)
+		if len(response.Validators) == 0 || len(validators) >= response.Total {
+			return validators, nil
+		}
+
+		page++
+	}
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// monikers rarely change, but a new validator can pick the one that was only used by another one
+// before, so the mapping is refreshed now and then instead of being cached forever
+const validatorMonikersTTL = 5 * time.Minute
+
+var (
+	errValidatorNotFound  = errors.New("no validator with this moniker")
+	errValidatorAmbiguous = errors.New("several validators have this moniker")
+)
+
+var (
+	validatorMonikers      map[string][]string
+	validatorMonikersTime  time.Time
+	validatorMonikersMutex sync.Mutex
+)
+
+// getValidatorAddressByMoniker returns the operator address of the validator with the moniker,
+// compared case-insensitively. If several validators have it, it's an error, as there's
+// no way to tell which one was meant.
+func getValidatorAddressByMoniker(ctx context.Context, grpcConn *grpc.ClientConn, moniker string) (string, error) {
+	monikers, err := getValidatorMonikers(ctx, grpcConn)
+	if err != nil {
+		return "", err
+	}
+
+	addresses := monikers[strings.ToLower(moniker)]
+	switch len(addresses) {
+	case 0:
+		return "", errValidatorNotFound
+	case 1:
+		return addresses[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s", errValidatorAmbiguous, strings.Join(addresses, ", "))
+	}
+}
+
+// getValidatorMonikers returns the operator addresses by the lowercased monikers,
+// re-listing the validators if the cached ones are older than validatorMonikersTTL.
+// The lock is held while querying, so concurrent scrapes don't list them all at once.
+func getValidatorMonikers(ctx context.Context, grpcConn *grpc.ClientConn) (map[string][]string, error) {
+	validatorMonikersMutex.Lock()
+	defer validatorMonikersMutex.Unlock()
+
+	if validatorMonikers != nil && time.Since(validatorMonikersTime) < validatorMonikersTTL {
+		return validatorMonikers, nil
+	}
+
+	validators, err := queryValidators(ctx, grpcConn)
+	if err != nil {
+		return nil, err
+	}
+
+	monikers := map[string][]string{}
+	for _, validator := range validators {
+		moniker := strings.ToLower(validator.Description.Moniker)
+		monikers[moniker] = append(monikers[moniker], validator.OperatorAddress)
+	}
+
+	validatorMonikers = monikers
+	validatorMonikersTime = time.Now()
+
+	return validatorMonikers, nil
+}
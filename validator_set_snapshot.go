@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"google.golang.org/grpc"
+)
+
+var (
+	validatorSetSnapshot      *validatorSet
+	validatorSetSnapshotMutex sync.RWMutex
+)
+
+// refreshValidatorSetSnapshot queries the validators set every --validator-set-refresh-interval,
+// so /metrics/validators can return the metrics instantly instead of scanning the whole
+// validators set on each scrape.
+func refreshValidatorSetSnapshot(grpcConn *grpc.ClientConn) {
+	ticker := time.NewTicker(ValidatorSetRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		takeValidatorSetSnapshot(grpcConn)
+		<-ticker.C
+	}
+}
+
+func takeValidatorSetSnapshot(grpcConn *grpc.ClientConn) {
+	ctx := context.Background()
+	if ValidatorsScanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ValidatorsScanTimeout)
+		defer cancel()
+	}
+
+	snapshot := queryValidatorSet(ctx, grpcConn, &log)
+
+	// it's better to return a slightly outdated snapshot than an empty one
+	if len(snapshot.Validators) == 0 || snapshot.MaxValidators == 0 {
+		log.Warn().Msg("Could not refresh validators set snapshot, keeping the previous one")
+		return
+	}
+
+	validatorSetSnapshotMutex.Lock()
+	validatorSetSnapshot = &snapshot
+	validatorSetSnapshotMutex.Unlock()
+
+	log.Debug().
+		Int("validators", len(snapshot.Validators)).
+		Msg("Refreshed validators set snapshot")
+}
+
+// getValidatorSetSnapshot returns the latest snapshot, if there is one. The validators
+// are copied, as the handlers unpack their pubkeys, which modifies them in place.
+func getValidatorSetSnapshot() (validatorSet, bool) {
+	validatorSetSnapshotMutex.RLock()
+	defer validatorSetSnapshotMutex.RUnlock()
+
+	if validatorSetSnapshot == nil {
+		return validatorSet{}, false
+	}
+
+	snapshot := *validatorSetSnapshot
+	snapshot.Validators = make([]stakingtypes.Validator, len(validatorSetSnapshot.Validators))
+	for index, validator := range validatorSetSnapshot.Validators {
+		if validator.ConsensusPubkey != nil {
+			pubkey := *validator.ConsensusPubkey
+			validator.ConsensusPubkey = &pubkey
+		}
+
+		snapshot.Validators[index] = validator
+	}
+
+	return snapshot, true
+}
@@ -2,19 +2,21 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
 	"sync"
 	"time"
 
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/simapp"
-	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
 )
 
@@ -24,15 +26,15 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 
 	requestStart := time.Now()
 
-	sublogger := log.With().
-		Str("request-id", uuid.New().String()).
-		Logger()
+	sublogger := zerolog.Ctx(r.Context())
+
+	ctx := r.Context()
 
 	validatorsCommissionGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_commission",
 			Help:        "Commission of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -40,8 +42,8 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 	validatorsStatusGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_status",
-			Help:        "Status of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			Help:        "Bond status of the Cosmos-based blockchain validator: 0 is unspecified, 1 is unbonded, 2 is unbonding, 3 is bonded",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -49,8 +51,8 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 	validatorsJailedGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_jailed",
-			Help:        "Jailed status of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			Help:        "1 if the Cosmos-based blockchain validator is jailed, 0 if no",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -59,7 +61,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_tokens",
 			Help:        "Tokens of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom"},
 	)
@@ -68,7 +70,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_delegator_shares",
 			Help:        "Delegator shares of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom"},
 	)
@@ -77,7 +79,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_min_self_delegation",
 			Help:        "Self declared minimum self delegation shares of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker", "denom"},
 	)
@@ -86,7 +88,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_missed_blocks",
 			Help:        "Missed blocks of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -94,8 +96,26 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 	validatorsRankGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_rank",
-			Help:        "Rank of the Cosmos-based blockchain validator",
-			ConstLabels: ConstLabels,
+			Help:        "Rank of the bonded Cosmos-based blockchain validator by tokens, starting from 1",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorsVotingPowerGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_voting_power",
+			Help:        "Consensus voting power of the bonded Cosmos-based blockchain validator",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorsVotingPowerPercentGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_voting_power_percent",
+			Help:        "Share of the bonded tokens the bonded Cosmos-based blockchain validator has, in percent",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
@@ -104,25 +124,560 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		prometheus.GaugeOpts{
 			Name:        "cosmos_validators_active",
 			Help:        "1 if the Cosmos-based blockchain validator is in active set, 0 if no",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "moniker"},
 	)
 
+	validatorsMinBondedInSetGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_min_bonded_in_set",
+			Help:        "Tokens of the last validator in the active set, i.e. the amount needed to get into it",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"denom"},
+	)
+
+	validatorsCommissionNetworkAverageGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_commission_network_avg",
+			Help:        "Average commission of the active set validators, weighted by their bonded tokens",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	validatorsCommissionVsNetworkAverageGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validator_commission_vs_network_avg",
+			Help:        "Commission of the Cosmos-based blockchain validator minus the weighted average commission of the active set",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "moniker"},
+	)
+
+	validatorsNakamotoCoefficientGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_nakamoto_coefficient",
+			Help:        "Minimal amount of the active set validators that together have more than 1/3 of the voting power",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	validatorsGiniCoefficientGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_gini_coefficient",
+			Help:        "Gini coefficient of the active set validators voting power, 0 is perfect equality and 1 is maximal inequality",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	validatorsSnapshotAgeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_snapshot_age_seconds",
+			Help:        "Seconds since the validators set snapshot was taken, only reported if --validator-set-refresh-interval is set",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	validatorsTotalBondedTokensGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_total_bonded_tokens",
+			Help:        "Total tokens of the bonded Cosmos-based blockchain validators, by bond denom",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"denom"},
+	)
+
+	validatorsCountGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_count",
+			Help:        "Amount of validators, including the ones not in the active set",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	validatorsActiveSetTokensGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_validators_active_set_tokens",
+			Help:        "Total tokens of the validators in the active set",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"denom"},
+	)
+
+	emptyValidatorSetGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_exporter_empty_validator_set",
+			Help:        "1 if there are no bonded validators (like on a freshly started testnet), 0 if no",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	// with ?summary=true only the aggregates are returned, which is much less series on big chains
+	summary := r.URL.Query().Get("summary") == "true"
+
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(validatorsCommissionGauge)
-	registry.MustRegister(validatorsStatusGauge)
-	registry.MustRegister(validatorsJailedGauge)
-	registry.MustRegister(validatorsTokensGauge)
-	registry.MustRegister(validatorsDelegatorSharesGauge)
-	registry.MustRegister(validatorsMinSelfDelegationGauge)
-	registry.MustRegister(validatorsMissedBlocksGauge)
-	registry.MustRegister(validatorsRankGauge)
-	registry.MustRegister(validatorsIsActiveGauge)
-
-	var validators []stakingtypes.Validator
-	var signingInfos []slashingtypes.ValidatorSigningInfo
-	var validatorSetLength uint32
+	if !summary {
+		registry.MustRegister(validatorsCommissionGauge)
+		registry.MustRegister(validatorsStatusGauge)
+		registry.MustRegister(validatorsJailedGauge)
+		registry.MustRegister(validatorsTokensGauge)
+		registry.MustRegister(validatorsDelegatorSharesGauge)
+		registry.MustRegister(validatorsMinSelfDelegationGauge)
+		registry.MustRegister(validatorsMissedBlocksGauge)
+		registry.MustRegister(validatorsRankGauge)
+		registry.MustRegister(validatorsVotingPowerGauge)
+		registry.MustRegister(validatorsVotingPowerPercentGauge)
+		registry.MustRegister(validatorsIsActiveGauge)
+		registry.MustRegister(validatorsCommissionVsNetworkAverageGauge)
+	}
+	registry.MustRegister(validatorsMinBondedInSetGauge)
+	registry.MustRegister(validatorsCommissionNetworkAverageGauge)
+	registry.MustRegister(validatorsCountGauge)
+	registry.MustRegister(validatorsActiveSetTokensGauge)
+	registry.MustRegister(validatorsTotalBondedTokensGauge)
+	registry.MustRegister(validatorsNakamotoCoefficientGauge)
+	registry.MustRegister(validatorsGiniCoefficientGauge)
+	registry.MustRegister(emptyValidatorSetGauge)
+	if ValidatorSetRefreshInterval != 0 {
+		registry.MustRegister(validatorsSnapshotAgeGauge)
+	}
+
+	var validatorSet validatorSet
+	if snapshot, found := getValidatorSetSnapshot(); found {
+		sublogger.Debug().
+			Time("snapshot-time", snapshot.Time).
+			Msg("Using validators set snapshot")
+		validatorSet = snapshot
+		validatorsSnapshotAgeGauge.Set(time.Since(snapshot.Time).Seconds())
+	} else {
+		validatorSet = queryValidatorSet(ctx, grpcConn, sublogger)
+	}
+
+	validators := validatorSet.Validators
+	signingInfos := validatorSet.SigningInfos
+	validatorSetLength := validatorSet.MaxValidators
+
+	sublogger.Debug().
+		Int("signingLength", len(signingInfos)).
+		Int("validatorsLength", len(validators)).
+		Msg("Validators info")
+
+	activeSet := getActiveSet(validators, validatorSetLength)
+	activeValidators := make(map[string]bool, len(activeSet))
+	for _, validator := range activeSet {
+		activeValidators[validator.OperatorAddress] = true
+	}
+
+	// on a freshly started chain there might be no bonded validators at all,
+	// so all the aggregates are reported as 0 instead of NaN
+	emptyValidatorSet := len(activeSet) == 0
+	if emptyValidatorSet {
+		sublogger.Warn().Msg("There are no bonded validators")
+		emptyValidatorSetGauge.Set(1)
+	} else {
+		emptyValidatorSetGauge.Set(0)
+	}
+
+	if validatorSetLength != 0 {
+		if minBondedInSet, err := getMinBondedInSet(activeSet); err != nil {
+			sublogger.Error().
+				Err(err).
+				Msg("Could not parse min bonded tokens in set")
+		} else {
+			denom, amount := validatorSet.convertTokens(minBondedInSet)
+			validatorsMinBondedInSetGauge.With(prometheus.Labels{
+				"denom": denom,
+			}).Set(amount)
+		}
+	}
+
+	averageCommission, averageCommissionErr := getAverageCommission(activeSet)
+	if averageCommissionErr != nil {
+		sublogger.Error().
+			Err(averageCommissionErr).
+			Msg("Could not calculate average commission")
+	} else {
+		validatorsCommissionNetworkAverageGauge.Set(averageCommission)
+	}
+
+	validatorsNakamotoCoefficientGauge.Set(float64(getNakamotoCoefficient(activeSet)))
+	if giniCoefficient, err := getGiniCoefficient(activeSet); err != nil {
+		sublogger.Error().
+			Err(err).
+			Msg("Could not calculate Gini coefficient")
+	} else {
+		validatorsGiniCoefficientGauge.Set(giniCoefficient)
+	}
+	validatorsCountGauge.Set(float64(len(validators)))
+
+	activeSetTokens := sdk.ZeroInt()
+	for _, validator := range activeSet {
+		activeSetTokens = activeSetTokens.Add(validator.Tokens)
+	}
+
+	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+	if value, err := strconv.ParseFloat(activeSetTokens.String(), 64); err != nil {
+		sublogger.Error().
+			Err(err).
+			Msg("Could not parse active set tokens")
+	} else {
+		denom, amount := validatorSet.convertTokens(value)
+		validatorsActiveSetTokensGauge.With(prometheus.Labels{
+			"denom": denom,
+		}).Set(amount)
+	}
+
+	totalBondedTokens := sdk.ZeroInt()
+	for _, validator := range validators {
+		if validator.Status == stakingtypes.Bonded {
+			totalBondedTokens = totalBondedTokens.Add(validator.Tokens)
+		}
+	}
+
+	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+	if value, err := strconv.ParseFloat(totalBondedTokens.String(), 64); err != nil {
+		sublogger.Error().
+			Err(err).
+			Msg("Could not parse total bonded tokens")
+	} else {
+		denom, amount := validatorSet.convertTokens(value)
+		validatorsTotalBondedTokensGauge.With(prometheus.Labels{
+			"denom": denom,
+		}).Set(amount)
+	}
+
+	bondedRanks := getBondedRanks(validators)
+
+	if !summary {
+		for _, validator := range validators {
+			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+			rate, err := strconv.ParseFloat(validator.Commission.CommissionRates.Rate.String(), 64)
+			if err != nil {
+				sublogger.Error().
+					Err(err).
+					Str("address", validator.OperatorAddress).
+					Msg("Could not get commission")
+			} else {
+				validatorsCommissionGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+				}).Set(rate)
+
+				if averageCommissionErr == nil && !emptyValidatorSet {
+					validatorsCommissionVsNetworkAverageGauge.With(prometheus.Labels{
+						"address": validator.OperatorAddress,
+						"moniker": validator.Description.Moniker,
+					}).Set(rate - averageCommission)
+				}
+			}
+
+			validatorsStatusGauge.With(prometheus.Labels{
+				"address": validator.OperatorAddress,
+				"moniker": validator.Description.Moniker,
+			}).Set(float64(validator.Status))
+
+			// golang doesn't have a ternary operator, so we have to stick with this ugly solution
+			var jailed float64
+
+			if validator.Jailed {
+				jailed = 1
+			} else {
+				jailed = 0
+			}
+			validatorsJailedGauge.With(prometheus.Labels{
+				"address": validator.OperatorAddress,
+				"moniker": validator.Description.Moniker,
+			}).Set(jailed)
+
+			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+			if value, err := strconv.ParseFloat(validator.Tokens.String(), 64); err != nil {
+				sublogger.Error().
+					Str("address", validator.OperatorAddress).
+					Err(err).
+					Msg("Could not parse validator tokens")
+			} else {
+				denom, tokens := validatorSet.convertTokens(value)
+				validatorsTokensGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+					"denom":   denom,
+				}).Set(tokens)
+			}
+
+			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+			if value, err := strconv.ParseFloat(validator.DelegatorShares.String(), 64); err != nil {
+				sublogger.Error().
+					Str("address", validator.OperatorAddress).
+					Err(err).
+					Msg("Could not parse delegator shares")
+			} else {
+				denom, shares := validatorSet.convertTokens(value)
+				validatorsDelegatorSharesGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+					"denom":   denom,
+				}).Set(shares)
+			}
+
+			if value, err := strconv.ParseFloat(validator.MinSelfDelegation.String(), 64); err != nil {
+				sublogger.Error().
+					Str("address", validator.OperatorAddress).
+					Err(err).
+					Msg("Could not parse min self delegation")
+			} else {
+				denom, minSelfDelegation := validatorSet.convertTokens(value)
+				validatorsMinSelfDelegationGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+					"denom":   denom,
+				}).Set(minSelfDelegation)
+			}
+
+			// the unbonded validators are not ranked, so they don't shift the numbering
+			if rank, found := bondedRanks[validator.OperatorAddress]; found {
+				validatorsRankGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+				}).Set(float64(rank))
+
+				validatorsVotingPowerGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+				}).Set(float64(validator.ConsensusPower()))
+
+				if totalBondedTokens.IsPositive() {
+					// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+					share := validator.Tokens.ToDec().QuoInt(totalBondedTokens).MulInt64(100)
+					if value, err := strconv.ParseFloat(share.String(), 64); err != nil {
+						sublogger.Error().
+							Str("address", validator.OperatorAddress).
+							Err(err).
+							Msg("Could not parse voting power share")
+					} else {
+						validatorsVotingPowerPercentGauge.With(prometheus.Labels{
+							"address": validator.OperatorAddress,
+							"moniker": validator.Description.Moniker,
+						}).Set(value)
+					}
+				}
+			}
+
+			if validatorSetLength != 0 {
+				// golang doesn't have a ternary operator, so we have to stick with this ugly solution
+				var active float64
+
+				if activeValidators[validator.OperatorAddress] {
+					active = 1
+				} else {
+					active = 0
+				}
+
+				validatorsIsActiveGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+				}).Set(active)
+			}
+
+			pubKey, err := getValidatorConsAddress(validator, interfaceRegistry)
+			if err != nil {
+				sublogger.Error().
+					Str("address", validator.OperatorAddress).
+					Err(err).
+					Msg("Could not get validator consensus address, skipping signing info metrics")
+				continue
+			}
+
+			var signingInfo slashingtypes.ValidatorSigningInfo
+			found := false
+
+			for _, signingInfoIterated := range signingInfos {
+				if pubKey.String() == signingInfoIterated.Address {
+					found = true
+					signingInfo = signingInfoIterated
+					break
+				}
+			}
+
+			if !found {
+				sublogger.Debug().
+					Str("address", validator.OperatorAddress).
+					Msg("Could not get signing info for validator")
+				continue
+			}
+
+			if validator.Status == stakingtypes.Bonded {
+				validatorsMissedBlocksGauge.With(prometheus.Labels{
+					"address": validator.OperatorAddress,
+					"moniker": validator.Description.Moniker,
+				}).Set(float64(signingInfo.MissedBlocksCounter))
+			} else {
+				sublogger.Trace().
+					Str("address", validator.OperatorAddress).
+					Msg("Validator is not active, not returning missed blocks amount.")
+			}
+		}
+	}
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/validators").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
+
+// getActiveSet returns the validators that would be selected into the active set,
+// sorted by tokens descending. Same as the SDK does, it skips jailed validators
+// and the ones without consensus power, so they don't take a slot in the set.
+func getActiveSet(validators []stakingtypes.Validator, maxValidators uint32) []stakingtypes.Validator {
+	candidates := make([]stakingtypes.Validator, 0, len(validators))
+	for _, validator := range validators {
+		if validator.Jailed || validator.PotentialConsensusPower() <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, validator)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Tokens.GT(candidates[j].Tokens)
+	})
+
+	if len(candidates) > int(maxValidators) {
+		candidates = candidates[:maxValidators]
+	}
+
+	return candidates
+}
+
+// getMinBondedInSet returns the tokens of the last validator in the active set,
+// which is how much is needed to get into it, or 0 if the set is empty.
+func getMinBondedInSet(activeSet []stakingtypes.Validator) (float64, error) {
+	if len(activeSet) == 0 {
+		return 0, nil
+	}
+
+	// Not using .Int64() as the amounts in the base denom can overflow it
+	return strconv.ParseFloat(activeSet[len(activeSet)-1].Tokens.String(), 64)
+}
+
+// getBondedRanks returns the rank of each bonded validator by tokens, starting from 1.
+func getBondedRanks(validators []stakingtypes.Validator) map[string]int {
+	bonded := make([]stakingtypes.Validator, 0, len(validators))
+	for _, validator := range validators {
+		if validator.Status == stakingtypes.Bonded {
+			bonded = append(bonded, validator)
+		}
+	}
+
+	sort.SliceStable(bonded, func(i, j int) bool {
+		return bonded[i].Tokens.GT(bonded[j].Tokens)
+	})
+
+	ranks := make(map[string]int, len(bonded))
+	for index, validator := range bonded {
+		ranks[validator.OperatorAddress] = index + 1
+	}
+
+	return ranks
+}
+
+// getAverageCommission returns the commission rate of the passed validators, weighted by their tokens.
+func getAverageCommission(validators []stakingtypes.Validator) (float64, error) {
+	totalTokens := sdk.ZeroInt()
+	weightedCommission := sdk.ZeroDec()
+
+	for _, validator := range validators {
+		totalTokens = totalTokens.Add(validator.Tokens)
+		weightedCommission = weightedCommission.Add(validator.Commission.CommissionRates.Rate.MulInt(validator.Tokens))
+	}
+
+	if totalTokens.IsZero() {
+		return 0, nil
+	}
+
+	// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+	return strconv.ParseFloat(weightedCommission.QuoInt(totalTokens).String(), 64)
+}
+
+// getNakamotoCoefficient returns the minimal amount of validators that together have
+// more than 1/3 of the tokens, i.e. can halt the chain. Validators should be sorted
+// by tokens descending, as getActiveSet returns them.
+func getNakamotoCoefficient(validators []stakingtypes.Validator) int {
+	totalTokens := sdk.ZeroInt()
+	for _, validator := range validators {
+		totalTokens = totalTokens.Add(validator.Tokens)
+	}
+
+	cumulativeTokens := sdk.ZeroInt()
+	for index, validator := range validators {
+		cumulativeTokens = cumulativeTokens.Add(validator.Tokens)
+		if cumulativeTokens.MulRaw(3).GT(totalTokens) {
+			return index + 1
+		}
+	}
+
+	return 0
+}
+
+// getGiniCoefficient returns the Gini coefficient of the validators tokens.
+// Validators should be sorted by tokens descending, as getActiveSet returns them.
+func getGiniCoefficient(validators []stakingtypes.Validator) (float64, error) {
+	count := float64(len(validators))
+
+	var totalTokens, weightedTokens float64
+	for index, validator := range validators {
+		// Not using .Int64() as the amounts in the base denom can overflow it
+		tokens, err := strconv.ParseFloat(validator.Tokens.String(), 64)
+		if err != nil {
+			return 0, err
+		}
+
+		totalTokens += tokens
+		// the formula expects the values sorted ascending, so the rank is counted from the end
+		weightedTokens += (count - float64(index)) * tokens
+	}
+
+	if totalTokens == 0 {
+		return 0, nil
+	}
+
+	return 2*weightedTokens/(count*totalTokens) - (count+1)/count, nil
+}
+
+type validatorSet struct {
+	Validators    []stakingtypes.Validator
+	SigningInfos  []slashingtypes.ValidatorSigningInfo
+	MaxValidators uint32
+	BondDenom     string
+	Time          time.Time
+}
+
+// convertTokens converts the validators tokens, which are always in the bond denom, as the staking
+// module has a single one and the validators tokens don't have a denom of their own.
+// The bond denom is taken from the chain instead of assuming it's the one from --denom,
+// so the tokens of different denoms are never mixed under the same label.
+func (s validatorSet) convertTokens(amount float64) (string, float64) {
+	// if the staking params could not be queried, --denom is the best guess
+	if s.BondDenom == "" {
+		return Denom, amount / DenomCoefficient
+	}
+
+	return convertCoin(s.BondDenom, amount)
+}
+
+// queryValidatorSet queries everything needed to calculate the validators set metrics.
+// If some query fails, the error is logged and the corresponding field is left empty.
+func queryValidatorSet(ctx context.Context, grpcConn *grpc.ClientConn, sublogger *zerolog.Logger) validatorSet {
+	var validatorSet validatorSet
 
 	var wg sync.WaitGroup
 
@@ -132,15 +687,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		sublogger.Debug().Msg("Started querying validators")
 		queryStart := time.Now()
 
-		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		validatorsResponse, err := stakingClient.Validators(
-			context.Background(),
-			&stakingtypes.QueryValidatorsRequest{
-				Pagination: &querytypes.PageRequest{
-					Limit: Limit,
-				},
-			},
-		)
+		validators, err := queryValidators(ctx, grpcConn)
 		if err != nil {
 			sublogger.Error().Err(err).Msg("Could not get validators")
 			return
@@ -149,12 +696,12 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		sublogger.Debug().
 			Float64("request-time", time.Since(queryStart).Seconds()).
 			Msg("Finished querying validators")
-		validators = validatorsResponse.Validators
 
 		// sorting by delegator shares to display rankings
 		sort.Slice(validators, func(i, j int) bool {
-			return validators[i].DelegatorShares.RoundInt64() > validators[j].DelegatorShares.RoundInt64()
+			return validators[i].DelegatorShares.GT(validators[j].DelegatorShares)
 		})
+		validatorSet.Validators = validators
 	}()
 
 	wg.Add(1)
@@ -163,15 +710,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		sublogger.Debug().Msg("Started querying validators signing infos")
 		queryStart := time.Now()
 
-		slashingClient := slashingtypes.NewQueryClient(grpcConn)
-		signingInfosResponse, err := slashingClient.SigningInfos(
-			context.Background(),
-			&slashingtypes.QuerySigningInfosRequest{
-				Pagination: &querytypes.PageRequest{
-					Limit: Limit,
-				},
-			},
-		)
+		signingInfos, err := querySigningInfos(ctx, grpcConn)
 		if err != nil {
 			sublogger.Error().
 				Err(err).
@@ -182,7 +721,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		sublogger.Debug().
 			Float64("request-time", time.Since(queryStart).Seconds()).
 			Msg("Finished querying validator signing infos")
-		signingInfos = signingInfosResponse.Info
+		validatorSet.SigningInfos = signingInfos
 	}()
 
 	wg.Add(1)
@@ -193,7 +732,7 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 
 		stakingClient := stakingtypes.NewQueryClient(grpcConn)
 		paramsResponse, err := stakingClient.Params(
-			context.Background(),
+			ctx,
 			&stakingtypes.QueryParamsRequest{},
 		)
 		if err != nil {
@@ -206,147 +745,44 @@ func ValidatorsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Cl
 		sublogger.Debug().
 			Float64("request-time", time.Since(queryStart).Seconds()).
 			Msg("Finished querying staking params")
-		validatorSetLength = paramsResponse.Params.MaxValidators
+		validatorSet.MaxValidators = paramsResponse.Params.MaxValidators
+		validatorSet.BondDenom = paramsResponse.Params.BondDenom
 	}()
 
 	wg.Wait()
+	validatorSet.Time = time.Now()
 
-	sublogger.Debug().
-		Int("signingLength", len(signingInfos)).
-		Int("validatorsLength", len(validators)).
-		Msg("Validators info")
-
-	for index, validator := range validators {
-		// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-		rate, err := strconv.ParseFloat(validator.Commission.CommissionRates.Rate.String(), 64)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("address", validator.OperatorAddress).
-				Msg("Could not get commission")
-		} else {
-			validatorsCommissionGauge.With(prometheus.Labels{
-				"address": validator.OperatorAddress,
-				"moniker": validator.Description.Moniker,
-			}).Set(rate)
-		}
-
-		validatorsStatusGauge.With(prometheus.Labels{
-			"address": validator.OperatorAddress,
-			"moniker": validator.Description.Moniker,
-		}).Set(float64(validator.Status))
-
-		// golang doesn't have a ternary operator, so we have to stick with this ugly solution
-		var jailed float64
-
-		if validator.Jailed {
-			jailed = 1
-		} else {
-			jailed = 0
-		}
-		validatorsJailedGauge.With(prometheus.Labels{
-			"address": validator.OperatorAddress,
-			"moniker": validator.Description.Moniker,
-		}).Set(jailed)
-
-		validatorsTokensGauge.With(prometheus.Labels{
-			"address": validator.OperatorAddress,
-			"moniker": validator.Description.Moniker,
-			"denom":   Denom,
-		}).Set(float64(validator.Tokens.Int64()) / DenomCoefficient)
-
-		// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-		if value, err := strconv.ParseFloat(validator.DelegatorShares.String(), 64); err != nil {
-			sublogger.Error().
-				Str("address", validator.OperatorAddress).
-				Err(err).
-				Msg("Could not parse delegator shares")
-		} else {
-			validatorsDelegatorSharesGauge.With(prometheus.Labels{
-				"address": validator.OperatorAddress,
-				"moniker": validator.Description.Moniker,
-				"denom":   Denom,
-			}).Set(value / DenomCoefficient)
-		}
-
-		validatorsMinSelfDelegationGauge.With(prometheus.Labels{
-			"address": validator.OperatorAddress,
-			"moniker": validator.Description.Moniker,
-			"denom":   Denom,
-		}).Set(float64(validator.MinSelfDelegation.Int64()) / DenomCoefficient)
-
-		err = validator.UnpackInterfaces(interfaceRegistry) // Unpack interfaces, to populate the Anys' cached values
-		if err != nil {
-			sublogger.Error().
-				Str("address", validator.OperatorAddress).
-				Err(err).
-				Msg("Could not get unpack validator inferfaces")
-		}
-
-		pubKey, err := validator.GetConsAddr()
-		if err != nil {
-			sublogger.Error().
-				Str("address", validator.OperatorAddress).
-				Err(err).
-				Msg("Could not get validator pubkey")
-		}
-
-		var signingInfo slashingtypes.ValidatorSigningInfo
-		found := false
-
-		for _, signingInfoIterated := range signingInfos {
-			if pubKey.String() == signingInfoIterated.Address {
-				found = true
-				signingInfo = signingInfoIterated
-				break
-			}
-		}
-
-		if !found {
-			sublogger.Debug().
-				Str("address", validator.OperatorAddress).
-				Msg("Could not get signing info for validator")
-			continue
-		}
-
-		if validator.Status == stakingtypes.Bonded {
-			validatorsMissedBlocksGauge.With(prometheus.Labels{
-				"address": validator.OperatorAddress,
-				"moniker": validator.Description.Moniker,
-			}).Set(float64(signingInfo.MissedBlocksCounter))
-		} else {
-			sublogger.Trace().
-				Str("address", validator.OperatorAddress).
-				Msg("Validator is not active, not returning missed blocks amount.")
-		}
+	return validatorSet
+}
 
-		validatorsRankGauge.With(prometheus.Labels{
-			"address": validator.OperatorAddress,
-			"moniker": validator.Description.Moniker,
-		}).Set(float64(index + 1))
+// getValidatorConsAddress unpacks the validator consensus pubkey and returns its address.
+// A validator with a key type the exporter doesn't know shouldn't break the metrics
+// for all the others, so the error is counted and returned instead.
+func getValidatorConsAddress(
+	validator stakingtypes.Validator,
+	interfaceRegistry codectypes.InterfaceRegistry,
+) (sdk.ConsAddress, error) {
+	consAddress, err := unpackValidatorConsAddress(validator, interfaceRegistry)
+	if err != nil {
+		exporterConsensusKeyDecodeErrorsCounter.Inc()
+		return nil, err
+	}
 
-		if validatorSetLength != 0 {
-			// golang doesn't have a ternary operator, so we have to stick with this ugly solution
-			var active float64
+	return consAddress, nil
+}
 
-			if index+1 <= int(validatorSetLength) {
-				active = 1
-			} else {
-				active = 0
-			}
+func unpackValidatorConsAddress(
+	validator stakingtypes.Validator,
+	interfaceRegistry codectypes.InterfaceRegistry,
+) (sdk.ConsAddress, error) {
+	if validator.ConsensusPubkey == nil {
+		return nil, fmt.Errorf("validator has no consensus pubkey")
+	}
 
-			validatorsIsActiveGauge.With(prometheus.Labels{
-				"address": validator.OperatorAddress,
-				"moniker": validator.Description.Moniker,
-			}).Set(active)
-		}
+	// Unpack interfaces, to populate the Anys' cached values
+	if err := validator.UnpackInterfaces(interfaceRegistry); err != nil {
+		return nil, err
 	}
 
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
-	sublogger.Info().
-		Str("method", "GET").
-		Str("endpoint", "/metrics/validators").
-		Float64("request-time", time.Since(requestStart).Seconds()).
-		Msg("Request processed")
+	return validator.GetConsAddr()
 }
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"testing"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	"github.com/cosmos/cosmos-sdk/simapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestValidator(address string, tokens string, jailed bool) stakingtypes.Validator {
+	amount, ok := sdk.NewIntFromString(tokens)
+	if !ok {
+		panic("invalid tokens amount " + tokens)
+	}
+
+	return stakingtypes.Validator{
+		OperatorAddress: address,
+		Tokens:          amount,
+		Jailed:          jailed,
+	}
+}
+
+func getOperatorAddresses(validators []stakingtypes.Validator) []string {
+	addresses := make([]string, len(validators))
+	for index, validator := range validators {
+		addresses[index] = validator.OperatorAddress
+	}
+
+	return addresses
+}
+
+func TestGetActiveSet(t *testing.T) {
+	tests := []struct {
+		name          string
+		validators    []stakingtypes.Validator
+		maxValidators uint32
+		want          []string
+	}{
+		{
+			name: "jailed validators interspersed",
+			validators: []stakingtypes.Validator{
+				newTestValidator("a", "5000000", false),
+				newTestValidator("b", "9000000", true),
+				newTestValidator("c", "7000000", false),
+				newTestValidator("d", "6000000", true),
+				newTestValidator("e", "3000000", false),
+				newTestValidator("f", "4000000", false),
+			},
+			maxValidators: 3,
+			want:          []string{"c", "a", "f"},
+		},
+		{
+			name: "jailed validators don't take the slots",
+			validators: []stakingtypes.Validator{
+				newTestValidator("a", "9000000", true),
+				newTestValidator("b", "8000000", true),
+				newTestValidator("c", "1000000", false),
+			},
+			maxValidators: 2,
+			want:          []string{"c"},
+		},
+		{
+			name: "validators without consensus power are skipped",
+			validators: []stakingtypes.Validator{
+				newTestValidator("a", "2000000", false),
+				newTestValidator("b", "999999", false),
+			},
+			maxValidators: 2,
+			want:          []string{"a"},
+		},
+		{
+			name:          "empty validator set",
+			validators:    []stakingtypes.Validator{},
+			maxValidators: 100,
+			want:          []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := getOperatorAddresses(getActiveSet(test.validators, test.maxValidators))
+
+			if len(got) != len(test.want) {
+				t.Fatalf("got active set %v, want %v", got, test.want)
+			}
+
+			for index := range got {
+				if got[index] != test.want[index] {
+					t.Fatalf("got active set %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMinBondedInSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		validators []stakingtypes.Validator
+		want       float64
+	}{
+		{
+			name: "jailed validators interspersed",
+			validators: []stakingtypes.Validator{
+				newTestValidator("a", "5000000", false),
+				newTestValidator("b", "9000000", true),
+				newTestValidator("c", "7000000", false),
+				newTestValidator("d", "2000000", true),
+				newTestValidator("e", "3000000", false),
+			},
+			want: 3000000,
+		},
+		{
+			name: "18 decimals amounts overflowing int64",
+			validators: []stakingtypes.Validator{
+				newTestValidator("a", "90000000000000000000000", false),
+				newTestValidator("b", "20000000000000000000000", false),
+			},
+			want: 2e22,
+		},
+		{
+			name:       "empty validator set",
+			validators: []stakingtypes.Validator{},
+			want:       0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := getMinBondedInSet(getActiveSet(test.validators, 100))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEmptyValidatorSetAggregates(t *testing.T) {
+	tests := []struct {
+		name       string
+		validators []stakingtypes.Validator
+	}{
+		{
+			name:       "no validators",
+			validators: []stakingtypes.Validator{},
+		},
+		{
+			name: "no bonded validators",
+			validators: []stakingtypes.Validator{
+				newTestValidator("a", "0", false),
+				newTestValidator("b", "5000000", true),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			activeSet := getActiveSet(test.validators, 100)
+			if len(activeSet) != 0 {
+				t.Fatalf("got active set %v, want empty", getOperatorAddresses(activeSet))
+			}
+
+			minBonded, err := getMinBondedInSet(activeSet)
+			if err != nil || minBonded != 0 {
+				t.Errorf("got min bonded %v (error %v), want 0", minBonded, err)
+			}
+
+			averageCommission, err := getAverageCommission(activeSet)
+			if err != nil || averageCommission != 0 {
+				t.Errorf("got average commission %v (error %v), want 0", averageCommission, err)
+			}
+
+			if nakamoto := getNakamotoCoefficient(activeSet); nakamoto != 0 {
+				t.Errorf("got Nakamoto coefficient %d, want 0", nakamoto)
+			}
+
+			gini, err := getGiniCoefficient(activeSet)
+			if err != nil || gini != 0 {
+				t.Errorf("got Gini coefficient %v (error %v), want 0", gini, err)
+			}
+
+			if ranks := getBondedRanks(test.validators); len(ranks) != 0 {
+				t.Errorf("got ranks %v, want none", ranks)
+			}
+		})
+	}
+}
+
+func TestGetValidatorConsAddress(t *testing.T) {
+	interfaceRegistry := simapp.MakeTestEncodingConfig().InterfaceRegistry
+
+	pubKey := ed25519.GenPrivKey().PubKey()
+	supportedKey, err := codectypes.NewAnyWithValue(pubKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		consensusPubkey *codectypes.Any
+		want            sdk.ConsAddress
+		wantErr         bool
+	}{
+		{
+			name:            "supported key type",
+			consensusPubkey: supportedKey,
+			want:            sdk.ConsAddress(pubKey.Address()),
+		},
+		{
+			name: "unsupported key type",
+			consensusPubkey: &codectypes.Any{
+				TypeUrl: "/cosmos.crypto.bn254.PubKey",
+				Value:   []byte{0x0a, 0x02, 0x01, 0x02},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "no consensus pubkey",
+			consensusPubkey: nil,
+			wantErr:         true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errorsBefore := testutil.ToFloat64(exporterConsensusKeyDecodeErrorsCounter)
+
+			got, err := getValidatorConsAddress(
+				stakingtypes.Validator{ConsensusPubkey: test.consensusPubkey},
+				interfaceRegistry,
+			)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, test.wantErr)
+			}
+
+			if !got.Equals(test.want) {
+				t.Errorf("got address %s, want %s", got, test.want)
+			}
+
+			wantErrors := errorsBefore
+			if test.wantErr {
+				wantErrors++
+			}
+
+			if errors := testutil.ToFloat64(exporterConsensusKeyDecodeErrorsCounter); errors != wantErrors {
+				t.Errorf("got %v decode errors, want %v", errors, wantErrors)
+			}
+		})
+	}
+}
+
+func TestValidatorSetConvertTokens(t *testing.T) {
+	defer func(denomInfos map[string]denomInfo, denom string, coefficient float64) {
+		DenomInfos, Denom, DenomCoefficient = denomInfos, denom, coefficient
+	}(DenomInfos, Denom, DenomCoefficient)
+
+	// a chain with several bondable-looking denoms in the bank metadata,
+	// but only one of them is the staking bond denom
+	Denom, DenomCoefficient = "atom", 1000000
+	DenomInfos = map[string]denomInfo{
+		"uatom":  {Display: "atom", Coefficient: 1000000},
+		"astake": {Display: "stake", Coefficient: 1e18},
+		"uusdc":  {Display: "usdc", Coefficient: 1000000},
+	}
+
+	tests := []struct {
+		name       string
+		bondDenom  string
+		amount     float64
+		wantDenom  string
+		wantAmount float64
+	}{
+		{
+			name:       "bond denom differs from --denom",
+			bondDenom:  "astake",
+			amount:     2e22,
+			wantDenom:  "stake",
+			wantAmount: 20000,
+		},
+		{
+			name:       "bond denom is --denom",
+			bondDenom:  "uatom",
+			amount:     5000000,
+			wantDenom:  "atom",
+			wantAmount: 5,
+		},
+		{
+			name:       "bond denom is unknown",
+			bondDenom:  "",
+			amount:     5000000,
+			wantDenom:  "atom",
+			wantAmount: 5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			denom, amount := validatorSet{BondDenom: test.bondDenom}.convertTokens(test.amount)
+
+			if denom != test.wantDenom || amount != test.wantAmount {
+				t.Errorf("got %v %s, want %v %s", amount, denom, test.wantAmount, test.wantDenom)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// set with -ldflags when building, like
+// go build -ldflags "-X main.version=v1.0.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+func getVersionString() string {
+	return fmt.Sprintf(
+		"cosmos-exporter %s (commit %s, built at %s with %s)",
+		version,
+		commit,
+		date,
+		runtime.Version(),
+	)
+}
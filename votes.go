@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const govVoteMethod = "/cosmos.gov.v1beta1.Query/Vote"
+
+// isVoteNotFound returns true if the vote query failed because the voter hasn't voted.
+// cosmos-sdk v0.42 returns InvalidArgument ("voter: ... not found for proposal: ...")
+// in this case, the newer versions return NotFound.
+func isVoteNotFound(err error) bool {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return true
+	case codes.InvalidArgument:
+		return strings.Contains(status.Convert(err).Message(), "not found")
+	default:
+		return false
+	}
+}
+
+// countUnvotedProposals returns how many of the proposals the voter hasn't voted on.
+// It returns the ID of the proposal the vote of which could not be queried along with the error.
+func countUnvotedProposals(
+	ctx context.Context,
+	govClient govtypes.QueryClient,
+	proposals []govtypes.Proposal,
+	voter string,
+) (int, uint64, error) {
+	unvoted := 0
+
+	for _, proposal := range proposals {
+		_, err := govClient.Vote(
+			ctx,
+			&govtypes.QueryVoteRequest{
+				ProposalId: proposal.ProposalId,
+				Voter:      voter,
+			},
+		)
+		if isVoteNotFound(err) {
+			unvoted++
+			continue
+		}
+
+		if err != nil {
+			return 0, proposal.ProposalId, err
+		}
+	}
+
+	return unvoted, 0, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeGovClient returns the error set for the proposal from Vote, or a vote if there's none.
+type fakeGovClient struct {
+	govtypes.QueryClient
+	errors map[uint64]error
+}
+
+func (c fakeGovClient) Vote(
+	ctx context.Context,
+	request *govtypes.QueryVoteRequest,
+	opts ...grpc.CallOption,
+) (*govtypes.QueryVoteResponse, error) {
+	if err, ok := c.errors[request.ProposalId]; ok {
+		return nil, err
+	}
+
+	return &govtypes.QueryVoteResponse{}, nil
+}
+
+func TestCountUnvotedProposals(t *testing.T) {
+	// what cosmos-sdk v0.42 returns if there's no vote
+	notVoted := status.Errorf(codes.InvalidArgument, "voter: %v not found for proposal: %v", "cosmos1voter", 2)
+
+	proposals := []govtypes.Proposal{{ProposalId: 1}, {ProposalId: 2}, {ProposalId: 3}}
+
+	tests := []struct {
+		name           string
+		errors         map[uint64]error
+		wantUnvoted    int
+		wantProposalID uint64
+		wantErr        bool
+	}{
+		{
+			name:        "all voted",
+			errors:      map[uint64]error{},
+			wantUnvoted: 0,
+		},
+		{
+			name:        "not voted on v0.42",
+			errors:      map[uint64]error{2: notVoted},
+			wantUnvoted: 1,
+		},
+		{
+			name: "not voted on newer versions",
+			errors: map[uint64]error{
+				1: status.Error(codes.NotFound, "vote not found"),
+				3: notVoted,
+			},
+			wantUnvoted: 2,
+		},
+		{
+			name:           "other invalid argument",
+			errors:         map[uint64]error{3: status.Error(codes.InvalidArgument, "invalid voter address")},
+			wantProposalID: 3,
+			wantErr:        true,
+		},
+		{
+			name:           "node error",
+			errors:         map[uint64]error{1: errors.New("connection reset")},
+			wantProposalID: 1,
+			wantErr:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unvoted, proposalID, err := countUnvotedProposals(
+				context.Background(),
+				fakeGovClient{errors: test.errors},
+				proposals,
+				"cosmos1voter",
+			)
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, test.wantErr)
+			}
+
+			if unvoted != test.wantUnvoted {
+				t.Errorf("got %d unvoted proposals, want %d", unvoted, test.wantUnvoted)
+			}
+
+			if proposalID != test.wantProposalID {
+				t.Errorf("got failed proposal %d, want %d", proposalID, test.wantProposalID)
+			}
+		})
+	}
+}
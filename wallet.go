@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,34 +12,101 @@ import (
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	distributiontypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
 )
 
 func WalletHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
 	requestStart := time.Now()
 
-	sublogger := log.With().
-		Str("request-id", uuid.New().String()).
-		Logger()
-
-	address := r.URL.Query().Get("address")
-	myAddress, err := sdk.AccAddressFromBech32(address)
-	if err != nil {
-		sublogger.Error().
-			Str("address", address).
-			Err(err).
-			Msg("Could not get address")
+	sublogger := zerolog.Ctx(r.Context())
+
+	ctx := r.Context()
+
+	// both ?address=a&address=b and ?address=a,b are supported
+	var addresses []string
+	for _, addressParam := range r.URL.Query()["address"] {
+		for _, address := range strings.Split(addressParam, ",") {
+			if address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	if len(addresses) > 0 {
+		if uint64(len(addresses)) > Limit {
+			sublogger.Error().
+				Int("addresses", len(addresses)).
+				Uint64("limit", Limit).
+				Msg("Too many wallets requested")
+			http.Error(w, "Too many wallets requested", http.StatusBadRequest)
+			return
+		}
+	} else {
+		addresses = getDefaultWalletAddresses()
+	}
+
+	if len(addresses) == 0 {
+		sublogger.Error().Msg("Address is not provided and neither --default-wallet nor --default-to-watched is set")
+		http.Error(w, "Address is not provided", http.StatusBadRequest)
 		return
 	}
 
+	denom := r.URL.Query().Get("denom")
+
+	registry := getWalletRegistry(ctx, grpcConn, addresses, denom)
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/wallet?address="+strings.Join(addresses, ",")).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
+
+// getDefaultWalletAddresses returns the wallets to query if none is passed:
+// --default-wallet, or the watched ones if --default-to-watched is set.
+func getDefaultWalletAddresses() []string {
+	if DefaultWallet != "" {
+		return []string{DefaultWallet}
+	}
+
+	if DefaultToWatched {
+		// so the endpoint can be scraped by a static job without any relabeling
+		return WatchedWallets
+	}
+
+	return nil
+}
+
+// getWalletRegistry queries the wallets metrics and returns the registry with them,
+// so they can be served both on /metrics/wallet and on /metrics.
+func getWalletRegistry(ctx context.Context, grpcConn *grpc.ClientConn, addresses []string, denom string) *prometheus.Registry {
+	sublogger := zerolog.Ctx(ctx)
+
 	walletBalanceGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_wallet_balance",
 			Help:        "Balance of the Cosmos-based blockchain wallet",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom", "base_denom", "trace_path"},
+	)
+
+	walletBalanceUSDGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wallet_balance_usd",
+			Help:        "Balance of the Cosmos-based blockchain wallet in --denom, in USD",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "denom"},
 	)
@@ -47,7 +115,16 @@ func WalletHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_wallet_delegations",
 			Help:        "Delegations of the Cosmos-based blockchain wallet",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom", "delegated_to"},
+	)
+
+	walletDelegationUSDGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wallet_delegations_usd",
+			Help:        "Delegations of the Cosmos-based blockchain wallet, in USD",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "denom", "delegated_to"},
 	)
@@ -56,272 +133,495 @@ func WalletHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Client
 		prometheus.GaugeOpts{
 			Name:        "cosmos_wallet_redelegations",
 			Help:        "Redlegations of the Cosmos-based blockchain wallet",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "denom", "redelegated_from", "redelegated_to"},
 	)
 
+	walletRedelegatingGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wallet_redelegating",
+			Help:        "Redelegations of the Cosmos-based blockchain wallet by the Unix timestamp they complete at",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom", "src_validator", "dst_validator", "completion_time"},
+	)
+
 	walletUnbondingsGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_wallet_unbondings",
 			Help:        "Unbondings of the Cosmos-based blockchain wallet",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "denom", "unbonded_from"},
 	)
 
+	walletUnbondingGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wallet_unbonding",
+			Help:        "Unbondings of the Cosmos-based blockchain wallet by the Unix timestamp they complete at",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom", "validator", "completion_time"},
+	)
+
 	walletRewardsGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cosmos_wallet_rewards",
 			Help:        "Rewards of the Cosmos-based blockchain wallet",
-			ConstLabels: ConstLabels,
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom", "validator_address"},
+	)
+
+	walletRewardsUSDGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wallet_rewards_usd",
+			Help:        "Rewards of the Cosmos-based blockchain wallet in --denom, in USD",
+			ConstLabels: getConstLabels(),
 		},
 		[]string{"address", "denom", "validator_address"},
 	)
 
+	walletBalanceDeltaGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wallet_balance_delta",
+			Help:        "Balance change of the Cosmos-based blockchain wallet since the previous scrape",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "denom"},
+	)
+
+	walletWithdrawAddressDiffersGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wallet_withdraw_address_differs",
+			Help:        "1 if the rewards of the Cosmos-based blockchain wallet are withdrawn to another address, 0 if no",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"address", "withdraw_address"},
+	)
+
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(walletBalanceGauge)
 	registry.MustRegister(walletDelegationGauge)
 	registry.MustRegister(walletUnbondingsGauge)
+	registry.MustRegister(walletUnbondingGauge)
 	registry.MustRegister(walletRedelegationGauge)
+	registry.MustRegister(walletRedelegatingGauge)
 	registry.MustRegister(walletRewardsGauge)
+	registry.MustRegister(walletBalanceDeltaGauge)
+	registry.MustRegister(walletWithdrawAddressDiffersGauge)
+	if PriceSource != "" {
+		registry.MustRegister(walletBalanceUSDGauge)
+		registry.MustRegister(walletDelegationUSDGauge)
+		registry.MustRegister(walletRewardsUSDGauge)
+	}
 
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying balance")
-		queryStart := time.Now()
-
-		bankClient := banktypes.NewQueryClient(grpcConn)
-		bankRes, err := bankClient.AllBalances(
-			context.Background(),
-			&banktypes.QueryAllBalancesRequest{Address: myAddress.String()},
-		)
+	collectWalletMetrics := func(address string) {
+		myAddress, err := sdk.AccAddressFromBech32(address)
 		if err != nil {
 			sublogger.Error().
 				Str("address", address).
 				Err(err).
-				Msg("Could not get balance")
+				Msg("Could not get address")
 			return
 		}
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying balance")
+		var wg sync.WaitGroup
 
-		for _, balance := range bankRes.Balances {
-			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-			if value, err := strconv.ParseFloat(balance.Amount.String(), 64); err != nil {
-				sublogger.Error().
-					Str("address", address).
-					Err(err).
-					Msg("Could not parse balance")
-			} else {
-				walletBalanceGauge.With(prometheus.Labels{
-					"address": address,
-					"denom":   Denom,
-				}).Set(value / DenomCoefficient)
-			}
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying delegations")
-		queryStart := time.Now()
-
-		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		stakingRes, err := stakingClient.DelegatorDelegations(
-			context.Background(),
-			&stakingtypes.QueryDelegatorDelegationsRequest{DelegatorAddr: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get delegations")
-			return
-		}
+				Msg("Started querying balance")
+			queryStart := time.Now()
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying delegations")
+			var balances sdk.Coins
 
-		for _, delegation := range stakingRes.DelegationResponses {
-			// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-			if value, err := strconv.ParseFloat(delegation.Balance.Amount.String(), 64); err != nil {
-				sublogger.Error().
-					Str("address", address).
-					Err(err).
-					Msg("Could not get delegation")
-			} else {
-				walletDelegationGauge.With(prometheus.Labels{
-					"address":      address,
-					"denom":        Denom,
-					"delegated_to": delegation.Delegation.ValidatorAddress,
-				}).Set(value / DenomCoefficient)
-			}
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying unbonding delegations")
-		queryStart := time.Now()
-
-		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		stakingRes, err := stakingClient.DelegatorUnbondingDelegations(
-			context.Background(),
-			&stakingtypes.QueryDelegatorUnbondingDelegationsRequest{DelegatorAddr: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
-				Str("address", address).
-				Err(err).
-				Msg("Could not get unbonding delegations")
-			return
-		}
+			bankClient := banktypes.NewQueryClient(grpcConn)
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying unbonding delegations")
+			// no need to fetch all the tokens the wallet holds if only one of them is needed
+			if denom != "" {
+				bankRes, err := bankClient.Balance(
+					ctx,
+					&banktypes.QueryBalanceRequest{Address: myAddress.String(), Denom: denom},
+				)
+				if err != nil {
+					sublogger.Error().
+						Str("address", address).
+						Str("denom", denom).
+						Err(err).
+						Msg("Could not get balance")
+					return
+				}
 
-		for _, unbonding := range stakingRes.UnbondingResponses {
-			var sum float64 = 0
-			for _, entry := range unbonding.Entries {
-				// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-				if value, err := strconv.ParseFloat(entry.Balance.String(), 64); err != nil {
+				if bankRes.Balance != nil {
+					balances = sdk.Coins{*bankRes.Balance}
+				}
+			} else {
+				bankRes, err := bankClient.AllBalances(
+					ctx,
+					&banktypes.QueryAllBalancesRequest{Address: myAddress.String()},
+				)
+				if err != nil {
 					sublogger.Error().
 						Str("address", address).
 						Err(err).
-						Msg("Could not parse unbonding delegation")
-				} else {
-					sum += value
+						Msg("Could not get balance")
+					return
 				}
+
+				balances = bankRes.Balances
 			}
 
-			walletUnbondingsGauge.With(prometheus.Labels{
-				"address":       unbonding.DelegatorAddress,
-				"denom":         Denom, // unbonding does not have denom in response for some reason
-				"unbonded_from": unbonding.ValidatorAddress,
-			}).Set(sum / DenomCoefficient)
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying redelegations")
-		queryStart := time.Now()
-
-		stakingClient := stakingtypes.NewQueryClient(grpcConn)
-		stakingRes, err := stakingClient.Redelegations(
-			context.Background(),
-			&stakingtypes.QueryRedelegationsRequest{DelegatorAddr: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get redelegations")
-			return
-		}
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying balance")
 
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying redelegations")
+			for _, balance := range balances {
+				if !isWalletDenomAllowed(balance.Denom) {
+					sublogger.Trace().
+						Str("address", address).
+						Str("denom", balance.Denom).
+						Msg("Denom is not in allowlist, skipping")
+					continue
+				}
 
-		for _, redelegation := range stakingRes.RedelegationResponses {
-			var sum float64 = 0
-			for _, entry := range redelegation.Entries {
 				// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-				if value, err := strconv.ParseFloat(entry.Balance.String(), 64); err != nil {
+				if value, err := strconv.ParseFloat(balance.Amount.String(), 64); err != nil {
 					sublogger.Error().
 						Str("address", address).
 						Err(err).
-						Msg("Could not parse redelegation")
+						Msg("Could not parse balance")
 				} else {
-					sum += value
+					// IBC denoms are reported as ibc/<hash>, so the original denom is added to make sense of them
+					baseDenom, tracePath, err := getIBCDenomLabels(ctx, grpcConn, balance.Denom)
+					if err != nil {
+						sublogger.Warn().
+							Str("address", address).
+							Str("denom", balance.Denom).
+							Err(err).
+							Msg("Could not get IBC denom trace")
+					}
+
+					denom, amount := convertCoin(balance.Denom, value)
+					walletBalanceGauge.With(prometheus.Labels{
+						"address":    address,
+						"denom":      denom,
+						"base_denom": baseDenom,
+						"trace_path": tracePath,
+					}).Set(amount)
+
+					if usdValue, ok := getUSDValue(denom, amount); ok {
+						walletBalanceUSDGauge.With(prometheus.Labels{
+							"address": address,
+							"denom":   denom,
+						}).Set(usdValue)
+					}
+
+					sampleKey := "wallet_balance/" + address + "/" + balance.Denom
+					if delta, _, found := samples.Delta(sampleKey, value, time.Now()); found {
+						_, delta = convertCoinUncounted(balance.Denom, samples.Smooth(sampleKey, delta))
+						walletBalanceDeltaGauge.With(prometheus.Labels{
+							"address": address,
+							"denom":   denom,
+						}).Set(delta)
+					}
 				}
 			}
+		}()
 
-			walletRedelegationGauge.With(prometheus.Labels{
-				"address":          redelegation.Redelegation.DelegatorAddress,
-				"denom":            Denom, // redelegation does not have denom in response for some reason
-				"redelegated_from": redelegation.Redelegation.ValidatorSrcAddress,
-				"redelegated_to":   redelegation.Redelegation.ValidatorDstAddress,
-			}).Set(sum / DenomCoefficient)
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		sublogger.Debug().
-			Str("address", address).
-			Msg("Started querying rewards")
-		queryStart := time.Now()
-
-		distributionClient := distributiontypes.NewQueryClient(grpcConn)
-		distributionRes, err := distributionClient.DelegationTotalRewards(
-			context.Background(),
-			&distributiontypes.QueryDelegationTotalRewardsRequest{DelegatorAddress: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().
 				Str("address", address).
-				Err(err).
-				Msg("Could not get rewards")
-			return
-		}
-		sublogger.Debug().
-			Str("address", address).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying rewards")
+				Msg("Started querying delegations")
+			queryStart := time.Now()
+
+			delegations, err := queryDelegatorDelegations(ctx, grpcConn, myAddress.String())
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get delegations")
+				return
+			}
+
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying delegations")
 
-		for _, reward := range distributionRes.Rewards {
-			for _, entry := range reward.Reward {
+			for _, delegation := range delegations {
 				// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
-				if value, err := strconv.ParseFloat(entry.Amount.String(), 64); err != nil {
+				if value, err := strconv.ParseFloat(delegation.Balance.Amount.String(), 64); err != nil {
 					sublogger.Error().
 						Str("address", address).
 						Err(err).
-						Msg("Could not parse reward")
+						Msg("Could not get delegation")
 				} else {
-					walletRewardsGauge.With(prometheus.Labels{
-						"address":           address,
-						"denom":             Denom,
-						"validator_address": reward.ValidatorAddress,
+					walletDelegationGauge.With(prometheus.Labels{
+						"address":      address,
+						"denom":        Denom,
+						"delegated_to": delegation.Delegation.ValidatorAddress,
 					}).Set(value / DenomCoefficient)
+
+					if usdValue, ok := getUSDValue(Denom, value/DenomCoefficient); ok {
+						walletDelegationUSDGauge.With(prometheus.Labels{
+							"address":      address,
+							"denom":        Denom,
+							"delegated_to": delegation.Delegation.ValidatorAddress,
+						}).Set(usdValue)
+					}
 				}
 			}
-		}
-	}()
+		}()
 
-	wg.Wait()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying unbonding delegations")
+			queryStart := time.Now()
 
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
-	sublogger.Info().
-		Str("method", "GET").
-		Str("endpoint", "/metrics/wallet?address="+address).
-		Float64("request-time", time.Since(requestStart).Seconds()).
-		Msg("Request processed")
+			unbondings, err := queryDelegatorUnbondingDelegations(ctx, grpcConn, myAddress.String())
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get unbonding delegations")
+				return
+			}
+
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying unbonding delegations")
+
+			for _, unbonding := range unbondings {
+				var sum float64 = 0
+				for _, entry := range unbonding.Entries {
+					// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+					if value, err := strconv.ParseFloat(entry.Balance.String(), 64); err != nil {
+						sublogger.Error().
+							Str("address", address).
+							Err(err).
+							Msg("Could not parse unbonding delegation")
+					} else {
+						sum += value
+
+						// entries created in the same block complete at the same time, so adding them up
+						walletUnbondingGauge.With(prometheus.Labels{
+							"address":         unbonding.DelegatorAddress,
+							"denom":           Denom,
+							"validator":       unbonding.ValidatorAddress,
+							"completion_time": strconv.FormatInt(entry.CompletionTime.Unix(), 10),
+						}).Add(value / DenomCoefficient)
+					}
+				}
+
+				walletUnbondingsGauge.With(prometheus.Labels{
+					"address":       unbonding.DelegatorAddress,
+					"denom":         Denom, // unbonding does not have denom in response for some reason
+					"unbonded_from": unbonding.ValidatorAddress,
+				}).Set(sum / DenomCoefficient)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying redelegations")
+			queryStart := time.Now()
+
+			redelegations, err := queryRedelegations(
+				ctx,
+				grpcConn,
+				stakingtypes.QueryRedelegationsRequest{DelegatorAddr: myAddress.String()},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get redelegations")
+				return
+			}
+
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying redelegations")
+
+			for _, redelegation := range redelegations {
+				var sum float64 = 0
+				for _, entry := range redelegation.Entries {
+					// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+					if value, err := strconv.ParseFloat(entry.Balance.String(), 64); err != nil {
+						sublogger.Error().
+							Str("address", address).
+							Err(err).
+							Msg("Could not parse redelegation")
+					} else {
+						sum += value
+
+						// entries created in the same block complete at the same time, so adding them up
+						walletRedelegatingGauge.With(prometheus.Labels{
+							"address":         redelegation.Redelegation.DelegatorAddress,
+							"denom":           Denom,
+							"src_validator":   redelegation.Redelegation.ValidatorSrcAddress,
+							"dst_validator":   redelegation.Redelegation.ValidatorDstAddress,
+							"completion_time": strconv.FormatInt(entry.RedelegationEntry.CompletionTime.Unix(), 10),
+						}).Add(value / DenomCoefficient)
+					}
+				}
+
+				walletRedelegationGauge.With(prometheus.Labels{
+					"address":          redelegation.Redelegation.DelegatorAddress,
+					"denom":            Denom, // redelegation does not have denom in response for some reason
+					"redelegated_from": redelegation.Redelegation.ValidatorSrcAddress,
+					"redelegated_to":   redelegation.Redelegation.ValidatorDstAddress,
+				}).Set(sum / DenomCoefficient)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying rewards")
+			queryStart := time.Now()
+
+			distributionClient := distributiontypes.NewQueryClient(grpcConn)
+			distributionRes, err := distributionClient.DelegationTotalRewards(
+				ctx,
+				&distributiontypes.QueryDelegationTotalRewardsRequest{DelegatorAddress: myAddress.String()},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get rewards")
+				return
+			}
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying rewards")
+
+			for _, reward := range distributionRes.Rewards {
+				for _, entry := range reward.Reward {
+					if !isRewardsDenomAllowed(entry.Denom) {
+						continue
+					}
+
+					// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+					if value, err := strconv.ParseFloat(entry.Amount.String(), 64); err != nil {
+						sublogger.Error().
+							Str("address", address).
+							Err(err).
+							Msg("Could not parse reward")
+					} else {
+						denom, amount := convertCoin(entry.Denom, value)
+						walletRewardsGauge.With(prometheus.Labels{
+							"address":           address,
+							"denom":             denom,
+							"validator_address": reward.ValidatorAddress,
+						}).Set(amount)
+
+						if usdValue, ok := getUSDValue(denom, amount); ok {
+							walletRewardsUSDGauge.With(prometheus.Labels{
+								"address":           address,
+								"denom":             denom,
+								"validator_address": reward.ValidatorAddress,
+							}).Set(usdValue)
+						}
+					}
+				}
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying withdraw address")
+			queryStart := time.Now()
+
+			distributionClient := distributiontypes.NewQueryClient(grpcConn)
+			distributionRes, err := distributionClient.DelegatorWithdrawAddress(
+				ctx,
+				&distributiontypes.QueryDelegatorWithdrawAddressRequest{DelegatorAddress: myAddress.String()},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get withdraw address")
+				return
+			}
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying withdraw address")
+
+			var differs float64
+
+			if distributionRes.WithdrawAddress != myAddress.String() {
+				differs = 1
+			} else {
+				differs = 0
+			}
+
+			walletWithdrawAddressDiffersGauge.With(prometheus.Labels{
+				"address":          address,
+				"withdraw_address": distributionRes.WithdrawAddress,
+			}).Set(differs)
+		}()
+
+		wg.Wait()
+	}
+
+	var walletsWg sync.WaitGroup
+
+	// every wallet makes several queries, so not querying all of them at once
+	semaphore := make(chan struct{}, MaxConcurrency)
+
+	for _, address := range addresses {
+		walletsWg.Add(1)
+		go func(address string) {
+			defer walletsWg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			collectWalletMetrics(address)
+		}(address)
+	}
+
+	walletsWg.Wait()
+
+	return registry
+}
+
+func isWalletDenomAllowed(denom string) bool {
+	if len(WalletDenomAllowlist) == 0 {
+		return true
+	}
+
+	for _, allowedDenom := range WalletDenomAllowlist {
+		if allowedDenom == denom {
+			return true
+		}
+	}
+
+	return false
 }
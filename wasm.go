@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// wasmd is not a dependency, so same as with the epochs, we only declare the messages we need.
+// Older wasmd versions only expose the v1beta1 service, so it's used as a fallback.
+const (
+	wasmSmartContractStateMethod       = "/cosmwasm.wasm.v1.Query/SmartContractState"
+	wasmSmartContractStateLegacyMethod = "/cosmwasm.wasm.v1beta1.Query/SmartContractState"
+)
+
+type wasmSmartContractStateRequest struct {
+	Address   string `protobuf:"bytes,1,opt,name=address,proto3"`
+	QueryData []byte `protobuf:"bytes,2,opt,name=query_data,json=queryData,proto3"`
+}
+
+func (m *wasmSmartContractStateRequest) Reset()         { *m = wasmSmartContractStateRequest{} }
+func (m *wasmSmartContractStateRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*wasmSmartContractStateRequest) ProtoMessage()    {}
+
+type wasmSmartContractStateResponse struct {
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3"`
+}
+
+func (m *wasmSmartContractStateResponse) Reset()         { *m = wasmSmartContractStateResponse{} }
+func (m *wasmSmartContractStateResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*wasmSmartContractStateResponse) ProtoMessage()    {}
+
+func queryWasmSmartContractState(ctx context.Context, grpcConn *grpc.ClientConn, address string, query []byte) ([]byte, error) {
+	request := &wasmSmartContractStateRequest{Address: address, QueryData: query}
+
+	response := &wasmSmartContractStateResponse{}
+	err := grpcConn.Invoke(ctx, wasmSmartContractStateMethod, request, response)
+	if status.Code(err) == codes.Unimplemented {
+		response = &wasmSmartContractStateResponse{}
+		err = grpcConn.Invoke(ctx, wasmSmartContractStateLegacyMethod, request, response)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data, nil
+}
+
+// parseWasmQueryResult parses the contract response, which should be a JSON number.
+// Contracts usually return the large integers like Uint128 as strings, so they're accepted too.
+func parseWasmQueryResult(data []byte) (float64, error) {
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, err
+	}
+
+	switch value := result.(type) {
+	case float64:
+		return value, nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return 0, fmt.Errorf("contract returned %s, which is not a number", string(data))
+	}
+}
+
+func WasmHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := zerolog.Ctx(r.Context())
+
+	ctx := r.Context()
+
+	contract := r.URL.Query().Get("address")
+	if contract == "" {
+		sublogger.Error().Msg("Contract address is not provided")
+		http.Error(w, "Contract address is not provided", http.StatusBadRequest)
+		return
+	}
+
+	queryMsg, err := base64.StdEncoding.DecodeString(r.URL.Query().Get("query_msg"))
+	if err != nil || len(queryMsg) == 0 {
+		sublogger.Error().
+			Str("query_msg", r.URL.Query().Get("query_msg")).
+			Msg("Could not decode query_msg")
+		http.Error(w, "query_msg should be a base64-encoded JSON query", http.StatusBadRequest)
+		return
+	}
+
+	wasmQueryResultGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_wasm_query_result",
+			Help:        "Numeric result of the CosmWasm smart contract query",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"contract"},
+	)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(wasmQueryResultGauge)
+
+	sublogger.Debug().
+		Str("contract", contract).
+		Msg("Started querying contract state")
+	queryStart := time.Now()
+
+	data, err := queryWasmSmartContractState(ctx, grpcConn, contract, queryMsg)
+	if status.Code(err) == codes.Unimplemented {
+		sublogger.Debug().Msg("The chain doesn't have the wasm module, not querying contract state")
+	} else if err != nil {
+		sublogger.Error().
+			Str("contract", contract).
+			Err(err).
+			Msg("Could not query contract state")
+	} else {
+		sublogger.Debug().
+			Str("contract", contract).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying contract state")
+
+		if value, err := parseWasmQueryResult(data); err != nil {
+			sublogger.Error().
+				Str("contract", contract).
+				Err(err).
+				Msg("Could not parse contract query result")
+		} else {
+			wasmQueryResultGauge.With(prometheus.Labels{
+				"contract": contract,
+			}).Set(value)
+		}
+	}
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/wasm").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
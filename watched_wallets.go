@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func WatchedWalletsHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := zerolog.Ctx(r.Context())
+
+	ctx := r.Context()
+
+	watchedWalletsTotalBalanceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_watched_wallets_total_balance",
+			Help:        "Total balance of all the watched wallets",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"denom"},
+	)
+
+	watchedWalletsCountGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_watched_wallets_count",
+			Help:        "Amount of wallets whose balance was successfully included into the total",
+			ConstLabels: getConstLabels(),
+		},
+	)
+
+	watchedDelegationGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_watched_delegation",
+			Help:        "Delegation of the watched wallet to the watched validator",
+			ConstLabels: getConstLabels(),
+		},
+		[]string{"wallet", "validator", "denom"},
+	)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(watchedWalletsTotalBalanceGauge)
+	registry.MustRegister(watchedWalletsCountGauge)
+	registry.MustRegister(watchedDelegationGauge)
+
+	totals := map[string]float64{}
+	var walletsCount float64
+	var mutex sync.Mutex
+
+	var wg sync.WaitGroup
+
+	for _, address := range WatchedWallets {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+
+			myAddress, err := sdk.AccAddressFromBech32(address)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get address")
+				return
+			}
+
+			sublogger.Debug().
+				Str("address", address).
+				Msg("Started querying balance")
+			queryStart := time.Now()
+
+			bankClient := banktypes.NewQueryClient(grpcConn)
+			bankRes, err := bankClient.AllBalances(
+				ctx,
+				&banktypes.QueryAllBalancesRequest{Address: myAddress.String()},
+			)
+			if err != nil {
+				sublogger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get balance")
+				return
+			}
+
+			sublogger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying balance")
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			walletsCount++
+
+			for _, balance := range bankRes.Balances {
+				// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+				if value, err := strconv.ParseFloat(balance.Amount.String(), 64); err != nil {
+					sublogger.Error().
+						Str("address", address).
+						Err(err).
+						Msg("Could not parse balance")
+				} else {
+					totals[balance.Denom] += value
+				}
+			}
+		}(address)
+
+		for _, validator := range WatchedValidators {
+			wg.Add(1)
+			go func(address string, validator string) {
+				defer wg.Done()
+
+				sublogger.Debug().
+					Str("address", address).
+					Str("validator", validator).
+					Msg("Started querying delegation")
+				queryStart := time.Now()
+
+				stakingClient := stakingtypes.NewQueryClient(grpcConn)
+				delegationRes, err := stakingClient.Delegation(
+					ctx,
+					&stakingtypes.QueryDelegationRequest{
+						DelegatorAddr: address,
+						ValidatorAddr: validator,
+					},
+				)
+
+				// no delegation is a valid state, it's reported as 0 so it can be alerted on
+				var value float64
+				if status.Code(err) == codes.NotFound {
+					value = 0
+				} else if err != nil {
+					sublogger.Error().
+						Str("address", address).
+						Str("validator", validator).
+						Err(err).
+						Msg("Could not get delegation")
+					return
+				} else if value, err = strconv.ParseFloat(delegationRes.DelegationResponse.Balance.Amount.String(), 64); err != nil {
+					// because cosmos's dec doesn't have .toFloat64() method or whatever and returns everything as int
+					sublogger.Error().
+						Str("address", address).
+						Str("validator", validator).
+						Err(err).
+						Msg("Could not parse delegation")
+					return
+				}
+
+				sublogger.Debug().
+					Str("address", address).
+					Str("validator", validator).
+					Float64("request-time", time.Since(queryStart).Seconds()).
+					Msg("Finished querying delegation")
+
+				watchedDelegationGauge.With(prometheus.Labels{
+					"wallet":    address,
+					"validator": validator,
+					"denom":     Denom,
+				}).Set(value / DenomCoefficient)
+			}(address, validator)
+		}
+	}
+
+	wg.Wait()
+
+	for denom, total := range totals {
+		displayDenom, amount := convertCoin(denom, total)
+		watchedWalletsTotalBalanceGauge.With(prometheus.Labels{
+			"denom": displayDenom,
+		}).Set(amount)
+	}
+
+	watchedWalletsCountGauge.Set(walletsCount)
+
+	if handleQueryTimeout(w, r) {
+		return
+	}
+
+	h := promhttp.HandlerFor(getGatherer(registry), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/watched-wallets").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}